@@ -0,0 +1,29 @@
+// Command protocol-schema writes the JSON Schema for the WebSocket protocol
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+func main() {
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	data, err := protocol.SchemaJSON()
+	if err != nil {
+		log.Fatalf("Error building schema: %v", err)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatalf("Error writing schema: %v", err)
+	}
+}
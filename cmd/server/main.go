@@ -1,25 +1,216 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/aiplaybookin/tiffin-go/internal/assets"
+	"github.com/aiplaybookin/tiffin-go/internal/backplane"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/https"
 	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/static"
 )
 
 func main() {
-	srv := server.NewServer()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	logger := cfg.Logger(os.Stderr)
+	slog.SetDefault(logger)
+
+	var settings strings.Builder
+	cfg.Print(&settings)
+	fmt.Fprintf(os.Stderr, "Effective configuration:\n%s", settings.String())
+
+	srv := server.NewServer(cfg, logger)
+
+	hub, bp, err := joinBackplane(cfg, logger)
+	if err != nil {
+		logger.Error("connecting to backplane failed", "err", err)
+		os.Exit(1)
+	}
+	if bp != nil {
+		if err := srv.UseBackplane(bp); err != nil {
+			logger.Error("joining backplane failed", "err", err)
+			os.Exit(1)
+		}
+	}
 	srv.Start()
 
+	mux := http.NewServeMux()
+
 	// API routes
-	http.HandleFunc("/api/create", srv.HandleCreateGame)
-	http.HandleFunc("/api/join", srv.HandleJoinGame)
-	http.HandleFunc("/ws", srv.HandleWebSocket)
+	srv.RegisterRoutes(mux)
 
 	// Serve static files
-	fs := http.FileServer(http.Dir("./static"))
-	http.Handle("/", fs)
+	if cfg.Dev {
+		logger.Info("serving static files from disk", "dir", cfg.StaticDir)
+		mux.Handle("/", assets.Dir(cfg.StaticDir))
+	} else {
+		files, err := assets.New(static.FS)
+		if err != nil {
+			logger.Error("loading embedded static files failed", "err", err)
+			os.Exit(1)
+		}
+		mux.Handle("/", files)
+	}
+
+	proxies, err := https.ParseProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Error("parsing trusted proxies failed", "err", err)
+		os.Exit(1)
+	}
+
+	// Don't pin browsers to HTTPS with a throwaway certificate
+	hstsMaxAge := cfg.HSTSMaxAge
+	if cfg.TLSSelfSigned {
+		hstsMaxAge = 0
+	}
+
+	handler := https.HSTS(hstsMaxAge, mux)
+	httpServer := &http.Server{
+		Addr:    cfg.Addr,
+		Handler: proxies.Handler(handler),
+	}
+
+	var redirectServer *http.Server
+	if cfg.TLSEnabled() {
+		httpServer.TLSConfig, err = tlsConfig(cfg, logger)
+		if err != nil {
+			logger.Error("loading TLS certificate failed", "err", err)
+			os.Exit(1)
+		}
+
+		if cfg.HTTPRedirectAddr != "" {
+			redirectServer = &http.Server{
+				Addr:    cfg.HTTPRedirectAddr,
+				Handler: proxies.Handler(https.Redirect(cfg.Addr, handler)),
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		var err error
+		if cfg.TLSEnabled() {
+			logger.Info("server starting", "addr", cfg.Addr, "scheme", "https")
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			logger.Info("server starting", "addr", cfg.Addr, "scheme", "http")
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	if redirectServer != nil {
+		go func() {
+			logger.Info("redirecting HTTP to HTTPS", "addr", cfg.HTTPRedirectAddr)
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("redirect server failed", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Notify WebSocket clients first; http.Server.Shutdown doesn't track hijacked connections
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("draining WebSocket connections failed", "err", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("shutting down HTTP server failed", "err", err)
+	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("shutting down redirect server failed", "err", err)
+		}
+	}
+
+	// The server has released its games; instances still on the hub lose theirs
+	if bp != nil {
+		bp.Close()
+	}
+	if hub != nil {
+		hub.Close()
+	}
+
+	logger.Info("server stopped")
+}
+
+// joinBackplane runs the backplane hub if configured and connects to the
+// hub named by -backplane, or to our own. It returns nils when neither
+// flag is set.
+func joinBackplane(cfg *config.Config, logger *slog.Logger) (*backplane.Hub, *backplane.Remote, error) {
+	var hub *backplane.Hub
+	addr := cfg.Backplane
+	if cfg.BackplaneListen != "" {
+		var err error
+		hub, err = backplane.Listen(cfg.BackplaneListen, cfg.BackplaneToken)
+		if err != nil {
+			return nil, nil, fmt.Errorf("starting backplane hub: %w", err)
+		}
+		logger.Info("backplane hub listening", "addr", hub.Addr().String())
+		if addr == "" {
+			addr = hub.Addr().String()
+		}
+	}
+	if addr == "" {
+		return nil, nil, nil
+	}
+
+	bp, err := backplane.Dial(addr, cfg.BackplaneToken)
+	if err != nil {
+		if hub != nil {
+			hub.Close()
+		}
+		return nil, nil, err
+	}
+	logger.Info("connected to backplane", "addr", addr)
+	return hub, bp, nil
+}
+
+// tlsConfig loads the configured certificate or generates a self-signed one
+func tlsConfig(cfg *config.Config, logger *slog.Logger) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if cfg.TLSSelfSigned {
+		cert, err = https.SelfSigned()
+		if err == nil {
+			logger.Warn("using a self-signed certificate; browsers will warn until it is trusted",
+				"sha256", https.Fingerprint(cert))
+		}
+	} else {
+		cert, err = tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
 }
@@ -2,4 +2,4 @@ module github.com/aiplaybookin/tiffin-go
 
 go 1.24.7
 
-require github.com/gorilla/websocket v1.5.3 // indirect
+require github.com/gorilla/websocket v1.5.3
@@ -0,0 +1,105 @@
+// Package access guards private rooms with passwords and signed invite links.
+//
+// Passwords are stored as a salted PBKDF2 hash. An invite is a token of the
+// form "<expiry>.<signature>", where the signature is an HMAC over the game
+// ID and expiry, so it can't be reused for another game or extended.
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned for rejected invites
+var (
+	ErrInvalidInvite = errors.New("invite link is not valid")
+	ErrInviteExpired = errors.New("invite link has expired")
+)
+
+// MaxPasswordLength is the longest room password accepted, in bytes
+const MaxPasswordLength = 64
+
+// PBKDF2 parameters. Room passwords guard short-lived games rather than
+// accounts, and joins are rate limited, so hashing stays cheap.
+const (
+	saltSize   = 16
+	keySize    = 32
+	iterations = 10000
+)
+
+// HashPassword returns a salted hash of password to store with a game
+func HashPassword(password string) []byte {
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+
+	key, _ := pbkdf2.Key(sha256.New, password, salt, iterations, keySize)
+	return append(salt, key...)
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash []byte, password string) bool {
+	if len(hash) != saltSize+keySize {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, hash[:saltSize], iterations, keySize)
+	return err == nil && subtle.ConstantTimeCompare(key, hash[saltSize:]) == 1
+}
+
+// Signer issues and checks invite tokens with a secret key
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer with a random key. Its invites stop working
+// when the process exits, along with the games they point to.
+func NewSigner() *Signer {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &Signer{key: key}
+}
+
+// Sign returns an invite token for gameID that expires at expires
+func (s *Signer) Sign(gameID string, expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 36)
+	return expiry + "." + base64.RawURLEncoding.EncodeToString(s.mac(gameID, expiry))
+}
+
+// Verify checks that token is an unexpired invite for gameID
+func (s *Signer) Verify(gameID, token string, now time.Time) error {
+	expiry, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidInvite
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(gameID, expiry)) {
+		return ErrInvalidInvite
+	}
+
+	unix, err := strconv.ParseInt(expiry, 36, 64)
+	if err != nil {
+		return ErrInvalidInvite
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrInviteExpired
+	}
+	return nil
+}
+
+// mac signs a game ID and encoded expiry
+func (s *Signer) mac(gameID, expiry string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(gameID))
+	h.Write([]byte{0})
+	h.Write([]byte(expiry))
+	return h.Sum(nil)
+}
@@ -0,0 +1,74 @@
+package access
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash := HashPassword("masala dosa")
+
+	tests := []struct {
+		name     string
+		hash     []byte
+		password string
+		want     bool
+	}{
+		{"correct", hash, "masala dosa", true},
+		{"wrong", hash, "masala idli", false},
+		{"case matters", hash, "Masala Dosa", false},
+		{"empty password", hash, "", false},
+		{"truncated hash", hash[:len(hash)-1], "masala dosa", false},
+		{"salt only", hash[:saltSize], "masala dosa", false},
+		{"empty hash", nil, "", false},
+		{"hash with extra byte", append(append([]byte{}, hash...), 0), "masala dosa", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("CheckPassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if string(HashPassword("masala dosa")) == string(hash) {
+		t.Error("two hashes of the same password are equal; the salt isn't random")
+	}
+}
+
+func TestVerifyInvite(t *testing.T) {
+	s := NewSigner()
+	now := time.Unix(1_800_000_000, 0)
+	token := s.Sign("KAZU-MEBO-DATE", now.Add(time.Hour))
+	expiry, sig, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name   string
+		gameID string
+		token  string
+		now    time.Time
+		want   error
+	}{
+		{"valid", "KAZU-MEBO-DATE", token, now, nil},
+		{"valid until the expiry", "KAZU-MEBO-DATE", token, now.Add(time.Hour), nil},
+		{"expired", "KAZU-MEBO-DATE", token, now.Add(time.Hour + time.Second), ErrInviteExpired},
+		{"other game", "BABA-BABA-BABA", token, now, ErrInvalidInvite},
+		{"tampered expiry", "KAZU-MEBO-DATE", strconv.FormatInt(now.Add(48*time.Hour).Unix(), 36) + "." + sig, now, ErrInvalidInvite},
+		{"bad base64", "KAZU-MEBO-DATE", expiry + ".not*base64!", now, ErrInvalidInvite},
+		{"missing separator", "KAZU-MEBO-DATE", expiry + sig, now, ErrInvalidInvite},
+		{"empty", "KAZU-MEBO-DATE", "", now, ErrInvalidInvite},
+		{"other signer", "KAZU-MEBO-DATE", NewSigner().Sign("KAZU-MEBO-DATE", now.Add(time.Hour)), now, ErrInvalidInvite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Verify(tt.gameID, tt.token, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
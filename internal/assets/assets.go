@@ -0,0 +1,216 @@
+// Package assets serves the web client's static files with ETags, cache
+// headers and precompressed variants.
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Encodings in order of preference, with the suffix of their sidecar files
+var encodings = []struct {
+	name   string
+	suffix string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// asset is one file held in memory with its compressed variants
+type asset struct {
+	body        []byte
+	encoded     map[string][]byte // Content-Encoding -> body
+	contentType string
+	etag        string // Hash of body; encoded variants add their encoding
+}
+
+// Handler serves files loaded once from a file system
+type Handler struct {
+	files map[string]*asset // Slash-separated path without the leading slash
+}
+
+// New loads every file in fsys. Files named like another file plus .br or
+// .gz are served as that file's compressed variants. Text files without a
+// .gz sidecar are gzipped here.
+func New(fsys fs.FS) (*Handler, error) {
+	raw := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		raw[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{files: make(map[string]*asset)}
+	for name, data := range raw {
+		if isSidecar(name, raw) {
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		a := &asset{
+			body:        data,
+			encoded:     make(map[string][]byte),
+			contentType: contentType(name),
+			etag:        hex.EncodeToString(sum[:8]),
+		}
+		for _, enc := range encodings {
+			if sidecar, exists := raw[name+enc.suffix]; exists {
+				a.encoded[enc.name] = sidecar
+			}
+		}
+		if _, exists := a.encoded["gzip"]; !exists && compressible(a.contentType) {
+			if gz := gzipBytes(data); len(gz) < len(data) {
+				a.encoded["gzip"] = gz
+			}
+		}
+		h.files[name] = a
+	}
+	return h, nil
+}
+
+// ServeHTTP serves the file at the request path; directories serve their
+// index.html
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	a, exists := h.files[name]
+	if !exists {
+		a, exists = h.files[path.Join(name, "index.html")]
+	}
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, encoding, etag := a.body, "", a.etag
+	for _, enc := range encodings {
+		if encoded, exists := a.encoded[enc.name]; exists && accepts(r, enc.name) {
+			body, encoding, etag = encoded, enc.name, a.etag+"-"+enc.name
+			break
+		}
+	}
+	etag = `"` + etag + `"`
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", cacheControl(a.contentType))
+	header.Set("Content-Type", a.contentType)
+	if len(a.encoded) > 0 {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+// Dir serves files straight from a directory on disk without caching, so
+// edits show up on the next reload
+func Dir(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// isSidecar reports whether name is a compressed copy of another file
+func isSidecar(name string, files map[string][]byte) bool {
+	for _, enc := range encodings {
+		if base, ok := strings.CutSuffix(name, enc.suffix); ok {
+			if _, exists := files[base]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contentType returns the MIME type for a file name
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// compressible reports whether a content type is worth gzipping
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "svg")
+}
+
+// cacheControl returns the caching policy for a content type. File names
+// carry no content hash, so HTML, CSS and JavaScript are revalidated with
+// their ETag on every load; otherwise a deploy could leave browsers running
+// an old client against a new protocol.
+func cacheControl(contentType string) string {
+	if compressible(contentType) {
+		return "no-cache"
+	}
+	return "public, max-age=86400"
+}
+
+// accepts reports whether the request accepts a content encoding
+func accepts(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(name), encoding) {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipBytes compresses data at the best compression level
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,134 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var page = strings.Repeat("<p>tiffin</p>\n", 100)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	h, err := New(fstest.MapFS{
+		"index.html":   {Data: []byte(page)},
+		"app.js":       {Data: []byte("console.log('hi')")}, // Too short to gain from gzip
+		"style.css":    {Data: []byte(page)},
+		"style.css.gz": {Data: []byte("precompressed")},
+		"style.css.br": {Data: []byte("brotli")},
+		"logo.png":     {Data: []byte(page)},
+		"notes.txt.br": {Data: []byte("orphan")}, // No notes.txt, so just a file
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func get(h http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+		wantBody       string // Body after gzip is undone; empty expects a 404
+	}{
+		{"gzip accepted", "/index.html", "gzip, deflate", "gzip", page},
+		{"gzip not accepted", "/index.html", "", "", page},
+		{"gzip refused", "/index.html", "gzip;q=0", "", page},
+		{"brotli without a sidecar is identity", "/index.html", "br", "", page},
+		{"directory index", "/", "gzip", "gzip", page},
+		{"brotli sidecar preferred", "/style.css", "gzip, br", "br", "brotli"},
+		{"brotli refused", "/style.css", "br;q=0, gzip", "gzip", "precompressed"},
+		{"gzip sidecar", "/style.css", "gzip", "gzip", "precompressed"},
+		{"sidecar identity fallback", "/style.css", "deflate", "", page},
+		{"short file stays plain", "/app.js", "gzip, br", "", "console.log('hi')"},
+		{"images are not gzipped", "/logo.png", "gzip", "", page},
+		{"sidecars are not served directly", "/style.css.br", "", "", ""},
+		{"orphan br file is just a file", "/notes.txt.br", "br", "", "orphan"},
+	}
+
+	h := newTestHandler(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(h, tt.path, tt.acceptEncoding)
+			if tt.wantBody == "" {
+				if rec.Code != http.StatusNotFound {
+					t.Errorf("status %d, want 404", rec.Code)
+				}
+				return
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding %q, want %q", got, tt.wantEncoding)
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantEncoding == "gzip" && tt.path != "/style.css" { // The test sidecar is not real gzip
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				body, _ = io.ReadAll(zr)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body %.40q..., want %.40q...", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	h := newTestHandler(t)
+
+	plain := get(h, "/index.html", "").Header().Get("ETag")
+	gzipped := get(h, "/index.html", "gzip").Header().Get("ETag")
+	if plain == "" || plain == gzipped {
+		t.Fatalf("ETags %q and %q, want distinct", plain, gzipped)
+	}
+	brotli := get(h, "/style.css", "br").Header().Get("ETag")
+	if css := get(h, "/style.css", "gzip").Header().Get("ETag"); brotli == css {
+		t.Errorf("brotli and gzip ETags both %q, want distinct", brotli)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", `"other", W/`+gzipped)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("status %d with %d bytes, want 304 and no body", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestNotFoundAndMethods(t *testing.T) {
+	h := newTestHandler(t)
+
+	if rec := get(h, "/missing.html", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file: status %d, want 404", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/index.html", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d Allow %q, want 405 and GET, HEAD", rec.Code, rec.Header().Get("Allow"))
+	}
+}
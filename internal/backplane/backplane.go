@@ -0,0 +1,97 @@
+// Package backplane lets several server instances share games. One
+// instance owns each game and runs it; the others relay their players'
+// requests and connections to the owner over publish/subscribe topics.
+//
+// Memory is an in-process implementation for a single process or for
+// tests that run several servers side by side. Across processes, one of
+// them runs a Hub and every instance connects to it with Dial.
+package backplane
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Backplane records game ownership and carries messages between instances
+type Backplane interface {
+	// Claim makes instanceID the owner of gameID unless another instance
+	// already owns it, and returns the owner either way
+	Claim(ctx context.Context, gameID, instanceID string) (string, error)
+
+	// Owner returns the instance that owns gameID, or "" if none does
+	Owner(ctx context.Context, gameID string) (string, error)
+
+	// Release gives up ownership of gameID if instanceID holds it
+	Release(ctx context.Context, gameID, instanceID string) error
+
+	// Publish delivers payload to every current subscriber of topic.
+	// Messages published by one caller reach each subscriber in order.
+	Publish(ctx context.Context, topic string, payload []byte) error
+
+	// Subscribe calls handler with each message published to topic, one
+	// at a time, until unsubscribe is called
+	Subscribe(topic string, handler func(payload []byte)) (unsubscribe func(), err error)
+}
+
+// ErrNoResponder is returned by Request when nobody answered in time
+var ErrNoResponder = errors.New("backplane: no response")
+
+// request wraps a request payload with the topic to answer on
+type request struct {
+	ReplyTo string `json:"reply_to"`
+	Body    []byte `json:"body"`
+}
+
+// Request publishes payload to topic and waits for the reply sent by a
+// handler registered with Serve
+func Request(ctx context.Context, bp Backplane, topic string, payload []byte) ([]byte, error) {
+	inbox := "inbox." + NewID()
+	replies := make(chan []byte, 1)
+	unsubscribe, err := bp.Subscribe(inbox, func(reply []byte) {
+		select {
+		case replies <- reply:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	msg, err := json.Marshal(request{ReplyTo: inbox, Body: payload})
+	if err != nil {
+		return nil, err
+	}
+	if err := bp.Publish(ctx, topic, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w on %s: %v", ErrNoResponder, topic, ctx.Err())
+	}
+}
+
+// Serve answers requests sent to topic with Request
+func Serve(bp Backplane, topic string, handler func(payload []byte) []byte) (unsubscribe func(), err error) {
+	return bp.Subscribe(topic, func(msg []byte) {
+		var req request
+		if err := json.Unmarshal(msg, &req); err != nil || req.ReplyTo == "" {
+			return
+		}
+		bp.Publish(context.Background(), req.ReplyTo, handler(req.Body))
+	})
+}
+
+// NewID returns a random identifier for an instance, inbox or connection
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package backplane
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Backplane shared by servers in the same process
+type Memory struct {
+	owners      map[string]string // GameID -> instance ID
+	subscribers map[string]map[*subscriber]bool
+	mu          sync.Mutex
+}
+
+// subscriber delivers one subscription's messages in order on its own
+// goroutine, so a slow handler never blocks a publisher
+type subscriber struct {
+	handler func([]byte)
+	queue   [][]byte
+	wake    chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+// NewMemory creates an empty in-process backplane
+func NewMemory() *Memory {
+	return &Memory{
+		owners:      make(map[string]string),
+		subscribers: make(map[string]map[*subscriber]bool),
+	}
+}
+
+// Claim makes instanceID the owner of gameID unless it already has one
+func (m *Memory) Claim(_ context.Context, gameID, instanceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if owner, exists := m.owners[gameID]; exists {
+		return owner, nil
+	}
+	m.owners[gameID] = instanceID
+	return instanceID, nil
+}
+
+// Owner returns the owner of gameID, or ""
+func (m *Memory) Owner(_ context.Context, gameID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.owners[gameID], nil
+}
+
+// Release forgets gameID's owner if it is instanceID
+func (m *Memory) Release(_ context.Context, gameID, instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.owners[gameID] == instanceID {
+		delete(m.owners, gameID)
+	}
+	return nil
+}
+
+// Publish queues payload for every subscriber of topic
+func (m *Memory) Publish(_ context.Context, topic string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for s := range m.subscribers[topic] {
+		s.push(payload)
+	}
+	return nil
+}
+
+// Subscribe starts delivering topic's messages to handler
+func (m *Memory) Subscribe(topic string, handler func([]byte)) (func(), error) {
+	s := &subscriber{
+		handler: handler,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+
+	m.mu.Lock()
+	if m.subscribers[topic] == nil {
+		m.subscribers[topic] = make(map[*subscriber]bool)
+	}
+	m.subscribers[topic][s] = true
+	m.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subscribers[topic], s)
+			if len(m.subscribers[topic]) == 0 {
+				delete(m.subscribers, topic)
+			}
+			m.mu.Unlock()
+			close(s.done)
+		})
+	}, nil
+}
+
+// push adds a message to the queue and wakes the delivery goroutine
+func (s *subscriber) push(payload []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, payload)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued messages until the subscription ends
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			payload := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			select {
+			case <-s.done:
+				return
+			default:
+				s.handler(payload)
+			}
+		}
+	}
+}
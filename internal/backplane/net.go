@@ -0,0 +1,578 @@
+package backplane
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds connecting to a hub
+const dialTimeout = 5 * time.Second
+
+// writeTimeout drops a peer that stops reading
+const writeTimeout = 10 * time.Second
+
+// subscribeTimeout bounds waiting for the hub to confirm a subscription
+const subscribeTimeout = 5 * time.Second
+
+// authTimeout bounds how long a new connection has to send its token
+const authTimeout = 5 * time.Second
+
+// Delays between a Remote's attempts to reconnect, doubling up to the max
+const (
+	reconnectMin = 100 * time.Millisecond
+	reconnectMax = 5 * time.Second
+)
+
+var (
+	// ErrClosed is returned by a Remote while its connection to the hub is
+	// down, and for good after Close
+	ErrClosed = errors.New("backplane: connection closed")
+
+	// ErrUnauthorized means the hub rejected a Remote's token
+	ErrUnauthorized = errors.New("backplane: hub rejected the token")
+
+	// errNoToken refuses to run a hub, or dial one, without a shared secret
+	errNoToken = errors.New("backplane: a token is required")
+)
+
+// Operations sent between a Remote and its hub
+const (
+	opHello       = "hello" // Remote's first frame, carrying the token
+	opClaim       = "claim"
+	opOwner       = "owner"
+	opRelease     = "release"
+	opPublish     = "publish"
+	opSubscribe   = "subscribe"
+	opUnsubscribe = "unsubscribe"
+	opReply       = "reply"   // Hub answers a request
+	opMessage     = "message" // Hub delivers a subscribed topic's message
+)
+
+// frame is one line of JSON on a hub connection
+type frame struct {
+	Op         string `json:"op"`
+	ID         uint64 `json:"id,omitempty"`  // Request ID, echoed in the reply
+	Sub        uint64 `json:"sub,omitempty"` // Subscription the frame is about
+	GameID     string `json:"game_id,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Topic      string `json:"topic,omitempty"`
+	Payload    []byte `json:"payload,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Hub shares one Memory backplane with Remote clients over TCP, so
+// instances in separate processes can run games together. Remotes must
+// send the hub's token before anything else. It keeps no state on disk;
+// games claimed through a connection are released when it closes.
+type Hub struct {
+	mem   *Memory
+	ln    net.Listener
+	token []byte
+	conns map[*hubConn]bool
+	mu    sync.Mutex
+}
+
+// hubConn is one Remote connected to a hub
+type hubConn struct {
+	hub    *Hub
+	conn   net.Conn
+	enc    *json.Encoder
+	subs   map[uint64]func() // Subscription ID -> unsubscribe
+	claims map[string]string // GameID -> instance ID, for games claimed here
+	writes sync.Mutex
+}
+
+// Listen starts a hub on addr that admits Remotes dialing with token
+func Listen(addr, token string) (*Hub, error) {
+	if token == "" {
+		return nil, errNoToken
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hub{
+		mem:   NewMemory(),
+		ln:    ln,
+		token: []byte(token),
+		conns: make(map[*hubConn]bool),
+	}
+	go h.serve()
+	return h, nil
+}
+
+// Addr returns the address the hub listens on
+func (h *Hub) Addr() net.Addr {
+	return h.ln.Addr()
+}
+
+// Close stops accepting instances and disconnects the connected ones
+func (h *Hub) Close() error {
+	err := h.ln.Close()
+
+	h.mu.Lock()
+	for c := range h.conns {
+		c.conn.Close()
+	}
+	h.mu.Unlock()
+	return err
+}
+
+// serve accepts connections until the listener closes
+func (h *Hub) serve() {
+	for {
+		conn, err := h.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &hubConn{
+			hub:    h,
+			conn:   conn,
+			enc:    json.NewEncoder(conn),
+			subs:   make(map[uint64]func()),
+			claims: make(map[string]string),
+		}
+		h.mu.Lock()
+		h.conns[c] = true
+		h.mu.Unlock()
+
+		go c.serve()
+	}
+}
+
+// serve handles one connection's requests in order. Handling them one at
+// a time is what keeps each Remote's publishes in order.
+func (c *hubConn) serve() {
+	defer c.close()
+
+	dec := json.NewDecoder(bufio.NewReader(c.conn))
+	if !c.authenticate(dec) {
+		return
+	}
+	for {
+		var f frame
+		if err := dec.Decode(&f); err != nil {
+			return
+		}
+
+		reply := frame{Op: opReply, ID: f.ID}
+		switch f.Op {
+		case opClaim:
+			reply.Owner, _ = c.hub.mem.Claim(context.Background(), f.GameID, f.InstanceID)
+			if reply.Owner == f.InstanceID {
+				c.claims[f.GameID] = f.InstanceID
+			}
+		case opOwner:
+			reply.Owner, _ = c.hub.mem.Owner(context.Background(), f.GameID)
+		case opRelease:
+			c.hub.mem.Release(context.Background(), f.GameID, f.InstanceID)
+			delete(c.claims, f.GameID)
+		case opPublish:
+			c.hub.mem.Publish(context.Background(), f.Topic, f.Payload)
+		case opSubscribe:
+			if _, exists := c.subs[f.Sub]; exists {
+				reply.Error = fmt.Sprintf("subscription %d already exists", f.Sub)
+				break
+			}
+			sub := f.Sub
+			c.subs[sub], _ = c.hub.mem.Subscribe(f.Topic, func(payload []byte) {
+				c.write(frame{Op: opMessage, Sub: sub, Payload: payload})
+			})
+		case opUnsubscribe:
+			if unsubscribe, ok := c.subs[f.Sub]; ok {
+				unsubscribe()
+				delete(c.subs, f.Sub)
+			}
+		default:
+			reply.Error = fmt.Sprintf("unknown operation %q", f.Op)
+		}
+
+		// Publishes and unsubscribes are fire-and-forget
+		if f.ID != 0 {
+			c.write(reply)
+		}
+	}
+}
+
+// authenticate reads the connection's hello and checks its token. Nothing
+// else is handled until it passes.
+func (c *hubConn) authenticate(dec *json.Decoder) bool {
+	c.conn.SetReadDeadline(time.Now().Add(authTimeout))
+	var f frame
+	if err := dec.Decode(&f); err != nil {
+		return false
+	}
+	c.conn.SetReadDeadline(time.Time{})
+
+	reply := frame{Op: opReply, ID: f.ID}
+	ok := f.Op == opHello && subtle.ConstantTimeCompare([]byte(f.Token), c.hub.token) == 1
+	if !ok {
+		reply.Error = "invalid token"
+	}
+	c.write(reply)
+	return ok
+}
+
+// write sends a frame, closing the connection if the peer stops reading
+func (c *hubConn) write(f frame) {
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.enc.Encode(f); err != nil {
+		c.conn.Close()
+	}
+}
+
+// close ends the connection's subscriptions and gives up its games
+func (c *hubConn) close() {
+	c.conn.Close()
+	for _, unsubscribe := range c.subs {
+		unsubscribe()
+	}
+	for gameID, instanceID := range c.claims {
+		c.hub.mem.Release(context.Background(), gameID, instanceID)
+	}
+
+	c.hub.mu.Lock()
+	delete(c.hub.conns, c)
+	c.hub.mu.Unlock()
+}
+
+// Remote is a Backplane reached over TCP through a Hub. When the
+// connection drops it reconnects with backoff and restores its
+// subscriptions and claims; calls made while it is down fail with
+// ErrClosed.
+type Remote struct {
+	addr  string
+	token string
+
+	conn   net.Conn // nil while reconnecting
+	enc    *json.Encoder
+	writes sync.Mutex // Guards conn and enc
+
+	pending map[uint64]chan frame // Request ID -> reply; closed if the connection drops
+	subs    map[uint64]*remoteSub
+	claims  map[string]string // GameID -> instance ID, claimed again after a reconnect
+	nextID  uint64
+	mu      sync.Mutex
+
+	closed    chan struct{} // Closed by Close
+	closeOnce sync.Once
+}
+
+// remoteSub is a subscription a Remote restores after reconnecting
+type remoteSub struct {
+	topic string
+	*subscriber
+}
+
+// Dial connects to the hub at addr with the hub's token
+func Dial(addr, token string) (*Remote, error) {
+	if token == "" {
+		return nil, errNoToken
+	}
+
+	conn, dec, err := connect(addr, token)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Remote{
+		addr:    addr,
+		token:   token,
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		pending: make(map[uint64]chan frame),
+		subs:    make(map[uint64]*remoteSub),
+		claims:  make(map[string]string),
+		closed:  make(chan struct{}),
+	}
+	go r.run(conn, dec)
+	return r, nil
+}
+
+// connect dials a hub and sends the token, returning the decoder that
+// continues reading after the hub's answer
+func connect(addr, token string) (net.Conn, *json.Decoder, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	var reply frame
+	err = json.NewEncoder(conn).Encode(frame{Op: opHello, Token: token})
+	if err == nil {
+		err = dec.Decode(&reply)
+	}
+	if err == nil && reply.Error != "" {
+		err = ErrUnauthorized
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, dec, nil
+}
+
+// Close disconnects from the hub for good, which releases the games
+// claimed through this Remote
+func (r *Remote) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
+
+// Claim makes instanceID the owner of gameID unless it already has one
+func (r *Remote) Claim(ctx context.Context, gameID, instanceID string) (string, error) {
+	reply, err := r.call(ctx, frame{Op: opClaim, GameID: gameID, InstanceID: instanceID})
+	if err == nil && reply.Owner == instanceID {
+		r.mu.Lock()
+		r.claims[gameID] = instanceID
+		r.mu.Unlock()
+	}
+	return reply.Owner, err
+}
+
+// Owner returns the owner of gameID, or ""
+func (r *Remote) Owner(ctx context.Context, gameID string) (string, error) {
+	reply, err := r.call(ctx, frame{Op: opOwner, GameID: gameID})
+	return reply.Owner, err
+}
+
+// Release forgets gameID's owner if it is instanceID. The claim is
+// forgotten here first, so a reconnect never brings it back.
+func (r *Remote) Release(ctx context.Context, gameID, instanceID string) error {
+	r.mu.Lock()
+	if r.claims[gameID] == instanceID {
+		delete(r.claims, gameID)
+	}
+	r.mu.Unlock()
+
+	_, err := r.call(ctx, frame{Op: opRelease, GameID: gameID, InstanceID: instanceID})
+	return err
+}
+
+// Publish sends payload to the hub without waiting for delivery. The hub
+// handles a connection's frames in order, so messages stay in order.
+func (r *Remote) Publish(_ context.Context, topic string, payload []byte) error {
+	return r.write(frame{Op: opPublish, Topic: topic, Payload: payload})
+}
+
+// Subscribe starts delivering topic's messages to handler once the hub
+// has confirmed the subscription
+func (r *Remote) Subscribe(topic string, handler func([]byte)) (func(), error) {
+	s := &subscriber{
+		handler: handler,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.subs[id] = &remoteSub{topic: topic, subscriber: s}
+	r.mu.Unlock()
+
+	remove := func() {
+		r.mu.Lock()
+		delete(r.subs, id)
+		r.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+	if _, err := r.call(ctx, frame{Op: opSubscribe, Sub: id, Topic: topic}); err != nil {
+		remove()
+		r.write(frame{Op: opUnsubscribe, Sub: id}) // In case a reconnect restored it
+		return nil, err
+	}
+	go s.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			remove()
+			close(s.done)
+			r.write(frame{Op: opUnsubscribe, Sub: id})
+		})
+	}, nil
+}
+
+// call sends a request and waits for the hub's reply
+func (r *Remote) call(ctx context.Context, f frame) (frame, error) {
+	replies := make(chan frame, 1)
+
+	r.mu.Lock()
+	r.nextID++
+	f.ID = r.nextID
+	r.pending[f.ID] = replies
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, f.ID)
+		r.mu.Unlock()
+	}()
+
+	if err := r.write(f); err != nil {
+		return frame{}, err
+	}
+
+	select {
+	case reply, ok := <-replies:
+		if !ok {
+			return frame{}, ErrClosed
+		}
+		if reply.Error != "" {
+			return reply, fmt.Errorf("backplane: %s", reply.Error)
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return frame{}, ctx.Err()
+	}
+}
+
+// write sends a frame to the hub
+func (r *Remote) write(f frame) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if r.conn == nil {
+		return ErrClosed
+	}
+	r.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := r.enc.Encode(f); err != nil {
+		r.conn.Close() // The reader notices and reconnects
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// run reads from the hub, reconnecting whenever the connection drops,
+// until Close
+func (r *Remote) run(conn net.Conn, dec *json.Decoder) {
+	for conn != nil {
+		r.read(dec)
+		r.disconnect(conn)
+		conn, dec = r.reconnect()
+	}
+}
+
+// read dispatches replies and messages until the connection drops
+func (r *Remote) read(dec *json.Decoder) {
+	for {
+		var f frame
+		if err := dec.Decode(&f); err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		switch f.Op {
+		case opReply:
+			if replies, ok := r.pending[f.ID]; ok {
+				replies <- f
+			}
+		case opMessage:
+			if s, ok := r.subs[f.Sub]; ok {
+				s.push(f.Payload)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// disconnect forgets a dropped connection and fails the calls waiting on it
+func (r *Remote) disconnect(conn net.Conn) {
+	r.writes.Lock()
+	conn.Close()
+	r.conn, r.enc = nil, nil
+	r.writes.Unlock()
+
+	r.mu.Lock()
+	for id, replies := range r.pending {
+		close(replies)
+		delete(r.pending, id)
+	}
+	r.mu.Unlock()
+}
+
+// reconnect dials the hub with backoff until it answers, then restores
+// subscriptions and claims. It returns nils once Close is called.
+func (r *Remote) reconnect() (net.Conn, *json.Decoder) {
+	delay := reconnectMin
+	for {
+		select {
+		case <-r.closed:
+			return nil, nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectMax)
+
+		conn, dec, err := connect(r.addr, r.token)
+		if err != nil {
+			continue
+		}
+		if r.restore(conn) {
+			return conn, dec
+		}
+	}
+}
+
+// restore subscribes and claims again on a new connection, ahead of any
+// other write, then makes it the Remote's connection. The hub forgot both
+// when the old connection dropped. Neither waits for a reply: the hub
+// handles them before anything sent after.
+func (r *Remote) restore(conn net.Conn) bool {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	select {
+	case <-r.closed:
+		conn.Close()
+		return false
+	default:
+	}
+
+	r.mu.Lock()
+	frames := make([]frame, 0, len(r.subs)+len(r.claims))
+	for id, s := range r.subs {
+		frames = append(frames, frame{Op: opSubscribe, Sub: id, Topic: s.topic})
+	}
+	for gameID, instanceID := range r.claims {
+		frames = append(frames, frame{Op: opClaim, GameID: gameID, InstanceID: instanceID})
+	}
+	r.mu.Unlock()
+
+	enc := json.NewEncoder(conn)
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	for _, f := range frames {
+		if err := enc.Encode(f); err != nil {
+			conn.Close()
+			return false
+		}
+	}
+
+	r.conn, r.enc = conn, enc
+	return true
+}
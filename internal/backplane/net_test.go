@@ -0,0 +1,299 @@
+package backplane
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+const testToken = "test-backplane-token"
+
+// newTestHub starts a hub on a loopback port and dials it n times
+func newTestHub(t *testing.T, n int) (*Hub, []*Remote) {
+	t.Helper()
+
+	hub, err := Listen("127.0.0.1:0", testToken)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { hub.Close() })
+
+	remotes := make([]*Remote, n)
+	for i := range remotes {
+		r, err := Dial(hub.Addr().String(), testToken)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		remotes[i] = r
+	}
+	return hub, remotes
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestRemoteOwnership(t *testing.T) {
+	_, remotes := newTestHub(t, 2)
+	a, b := remotes[0], remotes[1]
+	ctx := testContext(t)
+
+	if owner, err := a.Claim(ctx, "GAME", "a"); err != nil || owner != "a" {
+		t.Fatalf("a claims: owner %q, err %v, want a", owner, err)
+	}
+	if owner, err := b.Claim(ctx, "GAME", "b"); err != nil || owner != "a" {
+		t.Fatalf("b claims: owner %q, err %v, want a", owner, err)
+	}
+	if owner, err := b.Owner(ctx, "GAME"); err != nil || owner != "a" {
+		t.Fatalf("Owner = %q, %v, want a", owner, err)
+	}
+
+	// Only the owner can release
+	if err := b.Release(ctx, "GAME", "b"); err != nil {
+		t.Fatalf("b releases: %v", err)
+	}
+	if owner, _ := b.Owner(ctx, "GAME"); owner != "a" {
+		t.Fatalf("owner %q after release by b, want a", owner)
+	}
+	if err := a.Release(ctx, "GAME", "a"); err != nil {
+		t.Fatalf("a releases: %v", err)
+	}
+	if owner, _ := b.Owner(ctx, "GAME"); owner != "" {
+		t.Fatalf("owner %q after release by a, want none", owner)
+	}
+}
+
+func TestRemoteReleasesOnDisconnect(t *testing.T) {
+	_, remotes := newTestHub(t, 2)
+	a, b := remotes[0], remotes[1]
+	ctx := testContext(t)
+
+	if _, err := a.Claim(ctx, "GAME", "a"); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	a.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		owner, err := b.Owner(ctx, "GAME")
+		if err != nil {
+			t.Fatalf("Owner: %v", err)
+		}
+		if owner == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("owner still %q after disconnect", owner)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := a.Owner(ctx, "GAME"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Owner on closed remote: %v, want ErrClosed", err)
+	}
+}
+
+func TestRemotePublishInOrder(t *testing.T) {
+	_, remotes := newTestHub(t, 2)
+	pub, sub := remotes[0], remotes[1]
+	ctx := testContext(t)
+
+	const count = 500
+	got := make(chan string, count)
+	unsubscribe, err := sub.Subscribe("topic", func(payload []byte) {
+		got <- string(payload)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		if err := pub.Publish(ctx, "topic", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case msg := <-got:
+			if msg != fmt.Sprint(i) {
+				t.Fatalf("message %d is %q", i, msg)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d messages", i)
+		}
+	}
+
+	unsubscribe()
+	pub.Publish(ctx, "topic", []byte("late"))
+	// A round trip on the same connection means the hub has handled "late"
+	if _, err := pub.Owner(ctx, "sync"); err != nil {
+		t.Fatalf("Owner: %v", err)
+	}
+	select {
+	case msg := <-got:
+		t.Errorf("got %q after unsubscribe", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRemoteRequest(t *testing.T) {
+	_, remotes := newTestHub(t, 2)
+	client, server := remotes[0], remotes[1]
+	ctx := testContext(t)
+
+	if _, err := Serve(server, "echo", func(body []byte) []byte {
+		return append([]byte("echo: "), body...)
+	}); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	reply, err := Request(ctx, client, "echo", []byte("hello"))
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if string(reply) != "echo: hello" {
+		t.Errorf("reply %q, want %q", reply, "echo: hello")
+	}
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := Request(short, client, "nobody", nil); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("Request to nobody: %v, want ErrNoResponder", err)
+	}
+}
+
+func TestRemoteAfterHubCloses(t *testing.T) {
+	hub, remotes := newTestHub(t, 1)
+	r := remotes[0]
+
+	hub.Close()
+	ctx := testContext(t)
+	waitFor(t, "the connection to drop", func() bool {
+		_, err := r.Owner(ctx, "GAME")
+		return errors.Is(err, ErrClosed)
+	})
+
+	if _, err := r.Claim(ctx, "GAME", "a"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Claim: %v, want ErrClosed", err)
+	}
+	if err := r.Publish(ctx, "topic", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish: %v, want ErrClosed", err)
+	}
+	if _, err := r.Subscribe("topic", func([]byte) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe: %v, want ErrClosed", err)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestToken(t *testing.T) {
+	if _, err := Listen("127.0.0.1:0", ""); !errors.Is(err, errNoToken) {
+		t.Errorf("Listen without a token: %v, want errNoToken", err)
+	}
+
+	hub, _ := newTestHub(t, 0)
+	addr := hub.Addr().String()
+
+	if _, err := Dial(addr, ""); !errors.Is(err, errNoToken) {
+		t.Errorf("Dial without a token: %v, want errNoToken", err)
+	}
+	if _, err := Dial(addr, "wrong-token"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Dial with the wrong token: %v, want ErrUnauthorized", err)
+	}
+
+	// A peer that skips the hello is refused and hung up on
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	json.NewEncoder(conn).Encode(frame{Op: opClaim, ID: 1, GameID: "GAME", InstanceID: "intruder"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply frame
+	json.NewDecoder(bufio.NewReader(conn)).Decode(&reply)
+	if reply.Error == "" {
+		t.Errorf("hub answered %+v to a peer without a token", reply)
+	}
+	if owner, _ := hub.mem.Owner(context.Background(), "GAME"); owner != "" {
+		t.Errorf("peer without a token claimed GAME for %q", owner)
+	}
+}
+
+// Killing the hub and starting a new one on the same address: Remotes
+// reconnect on their own and restore their subscriptions and claims
+func TestRemoteReconnects(t *testing.T) {
+	hub, remotes := newTestHub(t, 2)
+	a, b := remotes[0], remotes[1]
+	addr := hub.Addr().String()
+	ctx := testContext(t)
+
+	if _, err := a.Claim(ctx, "GAME", "a"); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if _, err := a.Claim(ctx, "GONE", "a"); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if err := a.Release(ctx, "GONE", "a"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	got := make(chan string, 10)
+	if _, err := a.Subscribe("topic", func(payload []byte) { got <- string(payload) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	hub.Close()
+	waitFor(t, "the connection to drop", func() bool {
+		_, err := b.Owner(ctx, "GAME")
+		return errors.Is(err, ErrClosed)
+	})
+
+	restarted, err := Listen(addr, testToken)
+	if err != nil {
+		t.Fatalf("Listen again: %v", err)
+	}
+	t.Cleanup(func() { restarted.Close() })
+
+	waitFor(t, "a to reclaim GAME", func() bool {
+		owner, err := b.Owner(ctx, "GAME")
+		return err == nil && owner == "a"
+	})
+	if owner, _ := b.Owner(ctx, "GONE"); owner != "" {
+		t.Errorf("released game came back owned by %q", owner)
+	}
+
+	// a may not be back yet; b's publish is only sent once it is connected
+	waitFor(t, "a to reconnect", func() bool {
+		_, err := a.Owner(ctx, "sync")
+		return err == nil
+	})
+	if err := b.Publish(ctx, "topic", []byte("after restart")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case msg := <-got:
+		if msg != "after restart" {
+			t.Errorf("got %q, want %q", msg, "after restart")
+		}
+	case <-ctx.Done():
+		t.Fatal("subscription not restored after reconnect")
+	}
+}
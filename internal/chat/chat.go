@@ -0,0 +1,79 @@
+// Package chat validates in-game chat messages and reactions.
+package chat
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Errors returned for rejected chat
+var (
+	ErrEmpty           = errors.New("chat message is empty")
+	ErrTooLong         = errors.New("chat message is too long")
+	ErrRejected        = errors.New("chat message was rejected")
+	ErrUnknownReaction = errors.New("unknown reaction")
+)
+
+// Filter is a hook that cleans or rejects chat text. It returns the text to
+// relay, or an error (usually ErrRejected) to drop the message.
+type Filter func(text string) (string, error)
+
+// Reactions are the emoji players may react with
+var Reactions = []string{"👍", "👏", "😂", "😮", "😢", "🔥", "🍛", "☕"}
+
+// Clean trims text, strips control characters, checks its length in
+// characters and runs it through filter, which may be nil
+func Clean(text string, maxLength int, filter Filter) (string, error) {
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+
+	if text == "" {
+		return "", ErrEmpty
+	}
+	if utf8.RuneCountInString(text) > maxLength {
+		return "", ErrTooLong
+	}
+
+	if filter != nil {
+		return filter(text)
+	}
+	return text, nil
+}
+
+// IsReaction reports whether emoji is one of the allowed reactions
+func IsReaction(emoji string) bool {
+	for _, r := range Reactions {
+		if r == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskWords returns a Filter that replaces each listed word with asterisks,
+// ignoring case. It returns nil if there are no words.
+func MaskWords(words []string) Filter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return func(text string) (string, error) {
+		return pattern.ReplaceAllStringFunc(text, func(match string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		}), nil
+	}
+}
@@ -0,0 +1,387 @@
+// Package config loads server settings from flags, environment variables
+// and an optional JSON config file.
+//
+// Every setting is a flag. The same setting can be given as an environment
+// variable named TIFFIN_<FLAG> (upper case, dashes as underscores) or as a
+// key in the config file. Flags win over the environment, which wins over
+// the file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to flag names to form environment variable names
+const EnvPrefix = "TIFFIN_"
+
+// Config holds the server settings
+type Config struct {
+	ConfigFile       string        // Optional JSON config file
+	Addr             string        // Listen address
+	StaticDir        string        // Directory served at / in dev mode
+	Dev              bool          // Serve static files from StaticDir instead of the embedded copy
+	AllowedOrigins   []string      // WebSocket origins allowed to connect (empty allows all)
+	TLSCert          string        // PEM certificate file; serves HTTPS with TLSKey
+	TLSKey           string        // PEM private key file
+	TLSSelfSigned    bool          // Serve HTTPS with a generated development certificate
+	HTTPRedirectAddr string        // Plain HTTP address that redirects to HTTPS (empty = off)
+	HSTSMaxAge       time.Duration // Strict-Transport-Security lifetime sent over HTTPS (0 = off)
+	TrustedProxies   []string      // Proxy IPs or CIDR ranges whose X-Forwarded-* headers are believed
+	AdminToken       string        // Bearer token for the /admin API (empty disables it)
+	MaxGames         int           // Maximum concurrent games (0 = unlimited)
+	MaxTotalPlayers  int           // Maximum players across all games (0 = unlimited)
+	MinPlayers       int           // Players needed to start a game
+	MaxPlayers       int           // Seats per game
+	MaxConnections   int           // Open connections allowed per player
+	PingInterval     time.Duration // How often WebSocket clients are pinged
+	PongTimeout      time.Duration // WebSocket clients silent for this long are dropped
+	MaxMessageSize   int64         // Largest WebSocket message accepted, in bytes
+	CreateRate       int           // Games each IP may create per minute (0 = unlimited)
+	JoinRate         int           // Joins each IP may make per minute (0 = unlimited)
+	MessageRate      int           // WebSocket messages each IP may send per second (0 = unlimited)
+	ChatMaxLength    int           // Longest chat message, in characters
+	ChatHistory      int           // Chat messages kept per game and replayed on connect
+	ChatRate         int           // Chat messages and reactions each player may send per minute (0 = unlimited)
+	ChatBlocklist    []string      // Words masked out of chat
+	TurnTimeout      time.Duration // Time before unpicked cards are chosen automatically (0 = off)
+	LogLevel         string        // debug, info, warn or error
+	LogFormat        string        // text or json
+	ShutdownTimeout  time.Duration // Time allowed for connections to drain on shutdown
+	LobbyIdleTimeout time.Duration // Waiting or finished games expire after this long without activity
+	GameIdleTimeout  time.Duration // Games in progress expire after this long without activity
+	ReapInterval     time.Duration // How often idle games are checked
+	InviteTTL        time.Duration // How long host invite links stay valid
+	Backplane        string        // Address of the backplane hub shared with other instances (empty = standalone)
+	BackplaneListen  string        // Address to run the backplane hub on (empty = off)
+	BackplaneToken   string        // Shared secret instances present to the backplane hub
+}
+
+// Default returns the built-in settings
+func Default() *Config {
+	return &Config{
+		Addr:             ":8080",
+		StaticDir:        "./static",
+		HSTSMaxAge:       180 * 24 * time.Hour,
+		MaxGames:         1000,
+		MinPlayers:       2,
+		MaxPlayers:       5,
+		MaxConnections:   4,
+		PingInterval:     30 * time.Second,
+		PongTimeout:      60 * time.Second,
+		MaxMessageSize:   4096,
+		CreateRate:       10,
+		JoinRate:         30,
+		MessageRate:      20,
+		ChatMaxLength:    200,
+		ChatHistory:      50,
+		ChatRate:         20,
+		LogLevel:         "info",
+		LogFormat:        "text",
+		ShutdownTimeout:  10 * time.Second,
+		MaxTotalPlayers:  5000,
+		LobbyIdleTimeout: 30 * time.Minute,
+		GameIdleTimeout:  2 * time.Hour,
+		ReapInterval:     time.Minute,
+		InviteTTL:        24 * time.Hour,
+	}
+}
+
+// Load builds a config from defaults, the config file, the environment and
+// command-line arguments (without the program name)
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	fs := cfg.flagSet()
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Remember explicit flags so they can be reapplied last
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	path := cfg.ConfigFile
+	if _, ok := explicit["config"]; !ok {
+		path = os.Getenv(envName("config"))
+	}
+	if path != "" {
+		if err := loadFile(fs, path); err != nil {
+			return nil, err
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(envName(f.Name)); ok && envErr == nil {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("%s: %w", envName(f.Name), err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the settings are usable
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+	if c.Dev {
+		if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("static directory %q does not exist", c.StaticDir))
+		}
+	}
+	for _, origin := range c.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("allowed origin %q must look like scheme://host[:port]", origin))
+		}
+	}
+	if c.MaxGames < 0 {
+		errs = append(errs, errors.New("max-games must not be negative"))
+	}
+	if c.MaxTotalPlayers < 0 {
+		errs = append(errs, errors.New("max-total-players must not be negative"))
+	}
+	if c.LobbyIdleTimeout <= 0 || c.GameIdleTimeout <= 0 || c.ReapInterval <= 0 {
+		errs = append(errs, errors.New("lobby-idle-timeout, game-idle-timeout and reap-interval must be positive"))
+	}
+	if c.InviteTTL <= 0 {
+		errs = append(errs, errors.New("invite-ttl must be positive"))
+	}
+	if c.MinPlayers < 2 || c.MaxPlayers > 5 || c.MinPlayers > c.MaxPlayers {
+		errs = append(errs, fmt.Errorf("player limits %d-%d must be within 2-5", c.MinPlayers, c.MaxPlayers))
+	}
+	if c.MaxConnections < 1 {
+		errs = append(errs, errors.New("max-connections must be at least 1"))
+	}
+	if c.PingInterval <= 0 || c.PongTimeout <= c.PingInterval {
+		errs = append(errs, errors.New("ping-interval must be positive and shorter than pong-timeout"))
+	}
+	if c.MaxMessageSize < 512 {
+		errs = append(errs, errors.New("max-message-size must be at least 512 bytes"))
+	}
+	if c.CreateRate < 0 || c.JoinRate < 0 || c.MessageRate < 0 {
+		errs = append(errs, errors.New("create-rate, join-rate and message-rate must not be negative"))
+	}
+	if c.ChatMaxLength < 1 || c.ChatHistory < 0 || c.ChatRate < 0 {
+		errs = append(errs, errors.New("chat-max-length must be positive and chat-history and chat-rate must not be negative"))
+	}
+	if c.TurnTimeout < 0 {
+		errs = append(errs, errors.New("turn-timeout must not be negative"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown-timeout must be positive"))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+	}
+	if c.TLSCert != "" && c.TLSSelfSigned {
+		errs = append(errs, errors.New("tls-self-signed cannot be combined with tls-cert"))
+	}
+	if c.HTTPRedirectAddr != "" && !c.TLSEnabled() {
+		errs = append(errs, errors.New("http-redirect-addr needs tls-cert and tls-key or tls-self-signed"))
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("hsts-max-age must not be negative"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("trusted proxy %q must be an IP address or CIDR range", proxy))
+		}
+	}
+	if c.AdminToken != "" && len(c.AdminToken) < 16 {
+		errs = append(errs, errors.New("admin-token must be at least 16 characters"))
+	}
+	if _, _, err := net.SplitHostPort(c.Backplane); c.Backplane != "" && err != nil {
+		errs = append(errs, fmt.Errorf("backplane %q must look like host:port", c.Backplane))
+	}
+	if _, _, err := net.SplitHostPort(c.BackplaneListen); c.BackplaneListen != "" && err != nil {
+		errs = append(errs, fmt.Errorf("backplane-listen %q must look like host:port", c.BackplaneListen))
+	}
+	if (c.Backplane != "" || c.BackplaneListen != "") && len(c.BackplaneToken) < 16 {
+		errs = append(errs, errors.New("backplane-token of at least 16 characters is required with backplane or backplane-listen"))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log-format %q must be text or json", c.LogFormat))
+	}
+
+	return errors.Join(errs...)
+}
+
+// TLSEnabled reports whether the server listens for HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCert != "" || c.TLSSelfSigned
+}
+
+// Level returns the parsed log level
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log-level %q must be debug, info, warn or error", c.LogLevel)
+	}
+	return level, nil
+}
+
+// Logger returns a logger that writes to w at the configured level and format
+func (c *Config) Logger(w io.Writer) *slog.Logger {
+	level, _ := c.Level()
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Print writes the effective settings, one per line, hiding secrets
+func (c *Config) Print(w io.Writer) {
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if (f.Name == "admin-token" || f.Name == "backplane-token") && value != "" {
+			value = "(set)"
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", f.Name, value)
+	})
+}
+
+// flagSet binds a flag to each setting
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("tiffin-go", flag.ContinueOnError)
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile, "JSON config file")
+	fs.StringVar(&c.Addr, "addr", c.Addr, "listen address")
+	fs.StringVar(&c.StaticDir, "static", c.StaticDir, "directory of static files served in dev mode")
+	fs.BoolVar(&c.Dev, "dev", c.Dev, "serve static files from disk, uncached, for live editing")
+	fs.Var((*listValue)(&c.AllowedOrigins), "origins", "comma-separated WebSocket origins to allow (empty allows all)")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "PEM certificate file; serves HTTPS together with -tls-key")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "PEM private key file")
+	fs.BoolVar(&c.TLSSelfSigned, "tls-self-signed", c.TLSSelfSigned, "serve HTTPS with a generated certificate (development only)")
+	fs.StringVar(&c.HTTPRedirectAddr, "http-redirect-addr", c.HTTPRedirectAddr, "plain HTTP address that redirects to HTTPS, e.g. :80 (empty = off)")
+	fs.DurationVar(&c.HSTSMaxAge, "hsts-max-age", c.HSTSMaxAge, "Strict-Transport-Security lifetime sent over HTTPS (0 = off)")
+	fs.Var((*listValue)(&c.TrustedProxies), "trusted-proxies", "comma-separated proxy IPs or CIDR ranges whose X-Forwarded-* headers are believed")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "bearer token for the /admin API (empty disables it)")
+	fs.IntVar(&c.MaxGames, "max-games", c.MaxGames, "maximum concurrent games (0 = unlimited)")
+	fs.IntVar(&c.MaxTotalPlayers, "max-total-players", c.MaxTotalPlayers, "maximum players across all games (0 = unlimited)")
+	fs.IntVar(&c.MinPlayers, "min-players", c.MinPlayers, "players needed to start a game")
+	fs.IntVar(&c.MaxPlayers, "max-players", c.MaxPlayers, "seats per game")
+	fs.IntVar(&c.MaxConnections, "max-connections", c.MaxConnections, "open connections allowed per player (tabs or devices)")
+	fs.DurationVar(&c.PingInterval, "ping-interval", c.PingInterval, "how often to ping WebSocket clients")
+	fs.DurationVar(&c.PongTimeout, "pong-timeout", c.PongTimeout, "drop WebSocket clients silent for this long")
+	fs.Int64Var(&c.MaxMessageSize, "max-message-size", c.MaxMessageSize, "largest WebSocket message accepted, in bytes")
+	fs.IntVar(&c.CreateRate, "create-rate", c.CreateRate, "games each IP may create per minute (0 = unlimited)")
+	fs.IntVar(&c.JoinRate, "join-rate", c.JoinRate, "joins each IP may make per minute (0 = unlimited)")
+	fs.IntVar(&c.MessageRate, "message-rate", c.MessageRate, "WebSocket messages each IP may send per second (0 = unlimited)")
+	fs.IntVar(&c.ChatMaxLength, "chat-max-length", c.ChatMaxLength, "longest chat message, in characters")
+	fs.IntVar(&c.ChatHistory, "chat-history", c.ChatHistory, "chat messages kept per game and replayed on connect")
+	fs.IntVar(&c.ChatRate, "chat-rate", c.ChatRate, "chat messages and reactions each player may send per minute (0 = unlimited)")
+	fs.Var((*listValue)(&c.ChatBlocklist), "chat-blocklist", "comma-separated words masked out of chat")
+	fs.DurationVar(&c.TurnTimeout, "turn-timeout", c.TurnTimeout, "pick automatically for players who take longer than this (0 = off)")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log format: text or json")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed for connections to drain on shutdown")
+	fs.DurationVar(&c.LobbyIdleTimeout, "lobby-idle-timeout", c.LobbyIdleTimeout, "expire waiting or finished games after this long without activity")
+	fs.DurationVar(&c.GameIdleTimeout, "game-idle-timeout", c.GameIdleTimeout, "expire games in progress after this long without activity")
+	fs.DurationVar(&c.ReapInterval, "reap-interval", c.ReapInterval, "how often to check for idle games")
+	fs.DurationVar(&c.InviteTTL, "invite-ttl", c.InviteTTL, "how long invite links created by hosts stay valid")
+	fs.StringVar(&c.Backplane, "backplane", c.Backplane, "host:port of the backplane hub shared with other instances (empty = standalone)")
+	fs.StringVar(&c.BackplaneListen, "backplane-listen", c.BackplaneListen, "run the backplane hub for other instances on this address, e.g. :7070 (empty = off)")
+	fs.StringVar(&c.BackplaneToken, "backplane-token", c.BackplaneToken, "shared secret instances present to the backplane hub")
+	return fs
+}
+
+// loadFile applies a JSON object keyed by flag name
+func loadFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	for name, raw := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+
+		var value string
+		switch v := raw.(type) {
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		case []interface{}:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			value = strings.Join(parts, ",")
+		default:
+			return fmt.Errorf("config file %s: unsupported value for %q", path, name)
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// envName returns the environment variable for a flag
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// listValue is a comma-separated list flag
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
@@ -69,14 +69,8 @@ func DealCards(game *models.Game) {
 	deck := ShuffleDeck(CreateDeck())
 	cardsPerPlayer := game.CardsPerHand()
 
-	playerIDs := make([]string, 0, len(game.Players))
-	for id := range game.Players {
-		playerIDs = append(playerIDs, id)
-	}
-
 	cardIndex := 0
-	for _, playerID := range playerIDs {
-		player := game.Players[playerID]
+	for _, player := range game.Seats() {
 		player.Hand = []models.Card{}
 		for i := 0; i < cardsPerPlayer && cardIndex < len(deck); i++ {
 			player.Hand = append(player.Hand, deck[cardIndex])
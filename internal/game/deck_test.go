@@ -0,0 +1,102 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+func TestCreateDeck(t *testing.T) {
+	deck := CreateDeck()
+
+	total := 0
+	for _, n := range models.DeckComposition {
+		total += n
+	}
+	if len(deck) != total {
+		t.Fatalf("deck has %d cards, want %d", len(deck), total)
+	}
+
+	counts := make(map[models.CardType]int)
+	chaiIcons := make(map[int]int)
+	for _, card := range deck {
+		counts[card.Type]++
+		if card.Type == models.Chai {
+			chaiIcons[card.Value]++
+		} else if card.Value != 0 {
+			t.Errorf("%s card has value %d, want 0", card.Type, card.Value)
+		}
+	}
+
+	for cardType, want := range models.DeckComposition {
+		if counts[cardType] != want {
+			t.Errorf("%d %s cards, want %d", counts[cardType], cardType, want)
+		}
+	}
+
+	for icons := 1; icons <= 3; icons++ {
+		if chaiIcons[icons] != 4 {
+			t.Errorf("%d chai cards with %d icons, want 4", chaiIcons[icons], icons)
+		}
+	}
+	if len(chaiIcons) != 3 {
+		t.Errorf("chai icon values %v, want only 1-3", chaiIcons)
+	}
+}
+
+func TestShuffleDeckKeepsCards(t *testing.T) {
+	deck := CreateDeck()
+	shuffled := ShuffleDeck(deck)
+
+	if got, want := cardCounts(shuffled), cardCounts(deck); !equalCounts(got, want) {
+		t.Errorf("shuffled deck %v, want %v", got, want)
+	}
+	if &shuffled[0] == &deck[0] {
+		t.Error("ShuffleDeck shuffled the input in place")
+	}
+}
+
+func TestDealCards(t *testing.T) {
+	tests := []struct {
+		players int
+		hand    int
+	}{
+		{0, 0},
+		{1, 8},
+		{2, 10},
+		{3, 9},
+		{4, 8},
+		{5, 7},
+	}
+
+	deckSize := len(CreateDeck())
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d players", tt.players), func(t *testing.T) {
+			g := newTestGame(tt.players)
+			DealCards(g)
+
+			for _, p := range g.Players {
+				if len(p.Hand) != tt.hand {
+					t.Errorf("%s has %d cards, want %d", p.ID, len(p.Hand), tt.hand)
+				}
+			}
+			if want := deckSize - tt.players*tt.hand; len(g.Deck) != want {
+				t.Errorf("deck has %d cards left, want %d", len(g.Deck), want)
+			}
+			if err := checkConservation(g, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDealCardsReplacesHands(t *testing.T) {
+	g := newTestGame(3)
+	DealCards(g)
+	DealCards(g)
+
+	if err := checkConservation(g, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,19 +2,39 @@ package game
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
+	"time"
 
 	"github.com/aiplaybookin/tiffin-go/internal/models"
 )
 
+// Errors returned by the game engine
+var (
+	ErrCannotStart      = errors.New("cannot start game")
+	ErrGameStarted      = errors.New("game already started")
+	ErrPlayerNotFound   = errors.New("player not found")
+	ErrNotYourTurn      = errors.New("player has already selected a card this turn")
+	ErrInvalidCardIndex = errors.New("invalid card index")
+	ErrGameNotPlaying   = errors.New("game is not in playing state")
+	ErrNotAllSelected   = errors.New("not all players have selected")
+	ErrNoPlayers        = errors.New("no players in game")
+)
+
 // StartGame initializes a new game
 func StartGame(game *models.Game) error {
+	if game.State != models.StateWaiting {
+		return ErrGameStarted
+	}
+
 	if !game.CanStart() {
-		return errors.New("cannot start game: need 2-5 players")
+		return fmt.Errorf("%w: need %d-%d players", ErrCannotStart, game.MinPlayers, game.MaxPlayers)
 	}
 
 	game.State = models.StatePlaying
 	game.Round = 1
 	game.Turn = 1
+	game.StartedAt = time.Now()
 
 	// Initialize all players
 	for _, player := range game.Players {
@@ -32,19 +52,19 @@ func StartGame(game *models.Game) error {
 func SelectCard(game *models.Game, playerID string, cardIndex int) error {
 	player, exists := game.Players[playerID]
 	if !exists {
-		return errors.New("player not found")
+		return ErrPlayerNotFound
 	}
 
-	if player.HasSelected {
-		return errors.New("player has already selected a card this turn")
+	if game.State != models.StatePlaying {
+		return ErrGameNotPlaying
 	}
 
-	if cardIndex < 0 || cardIndex >= len(player.Hand) {
-		return errors.New("invalid card index")
+	if player.HasSelected {
+		return ErrNotYourTurn
 	}
 
-	if game.State != models.StatePlaying {
-		return errors.New("game is not in playing state")
+	if cardIndex < 0 || cardIndex >= len(player.Hand) {
+		return ErrInvalidCardIndex
 	}
 
 	// Remove card from hand and add to played cards
@@ -61,33 +81,53 @@ func SelectCard(game *models.Game, playerID string, cardIndex int) error {
 	return nil
 }
 
-// PassHands rotates hands clockwise to the next player
-func PassHands(game *models.Game) error {
-	if !game.AllPlayersSelected() {
-		return errors.New("not all players have selected")
+// AutoSelect picks a random card for a player who hasn't selected this turn
+func AutoSelect(game *models.Game, playerID string) error {
+	player, exists := game.Players[playerID]
+	if !exists {
+		return ErrPlayerNotFound
 	}
 
-	// Get ordered list of player IDs
-	playerIDs := make([]string, 0, len(game.Players))
-	for id := range game.Players {
-		playerIDs = append(playerIDs, id)
+	if len(player.Hand) == 0 {
+		return ErrInvalidCardIndex
+	}
+
+	return SelectCard(game, playerID, rand.Intn(len(player.Hand)))
+}
+
+// SelectForBots picks a card for every bot that hasn't selected this turn
+func SelectForBots(game *models.Game) {
+	if game.State != models.StatePlaying {
+		return
+	}
+
+	for id, player := range game.Players {
+		if player.IsBot && !player.HasSelected && len(player.Hand) > 0 {
+			AutoSelect(game, id)
+		}
+	}
+}
+
+// PassHands rotates hands clockwise to the next seat
+func PassHands(game *models.Game) error {
+	if !game.AllPlayersSelected() {
+		return ErrNotAllSelected
 	}
 
-	if len(playerIDs) == 0 {
-		return errors.New("no players in game")
+	players := game.Seats()
+	if len(players) == 0 {
+		return ErrNoPlayers
 	}
 
 	// Save current hands
-	hands := make(map[string][]models.Card)
-	for _, id := range playerIDs {
-		hands[id] = game.Players[id].Hand
+	hands := make([][]models.Card, len(players))
+	for i, player := range players {
+		hands[i] = player.Hand
 	}
 
 	// Pass hands clockwise
-	for i := 0; i < len(playerIDs); i++ {
-		currentPlayerID := playerIDs[i]
-		nextPlayerID := playerIDs[(i+1)%len(playerIDs)]
-		game.Players[nextPlayerID].Hand = hands[currentPlayerID]
+	for i := range players {
+		players[(i+1)%len(players)].Hand = hands[i]
 	}
 
 	// Reset selection flags
@@ -123,6 +163,7 @@ func EndRound(game *models.Game) error {
 	// Reset for next round or end game
 	if game.Round >= 3 {
 		game.State = models.StateFinished
+		game.FinishedAt = time.Now()
 		FinalScoring(game)
 	} else {
 		game.Round++
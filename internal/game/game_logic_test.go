@@ -0,0 +1,298 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// newTestGame creates a waiting game with players p0, p1, ... seated in
+// that order
+func newTestGame(players int) *models.Game {
+	g := models.NewGame("test", "p0")
+	joined := time.Now()
+	for i := 0; i < players; i++ {
+		p := models.NewPlayer(fmt.Sprintf("p%d", i), fmt.Sprintf("Player %d", i))
+		p.JoinedAt = joined.Add(time.Duration(i) * time.Second)
+		g.Players[p.ID] = p
+	}
+	return g
+}
+
+// startedGame creates a game with players that has been started
+func startedGame(t *testing.T, players int) *models.Game {
+	t.Helper()
+
+	g := newTestGame(players)
+	if err := StartGame(g); err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	return g
+}
+
+func TestStartGame(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		state   models.GameState
+		wantErr error
+	}{
+		{"two players", 2, models.StateWaiting, nil},
+		{"five players", 5, models.StateWaiting, nil},
+		{"one player", 1, models.StateWaiting, ErrCannotStart},
+		{"six players", 6, models.StateWaiting, ErrCannotStart},
+		{"already playing", 3, models.StatePlaying, ErrGameStarted},
+		{"finished", 3, models.StateFinished, ErrGameStarted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(tt.players)
+			g.State = tt.state
+
+			err := StartGame(g)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StartGame = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if g.State != models.StatePlaying || g.Round != 1 || g.Turn != 1 {
+				t.Errorf("state %s round %d turn %d, want playing 1 1", g.State, g.Round, g.Turn)
+			}
+			for _, p := range g.Players {
+				if len(p.Hand) != g.CardsPerHand() {
+					t.Errorf("%s has %d cards, want %d", p.ID, len(p.Hand), g.CardsPerHand())
+				}
+			}
+		})
+	}
+}
+
+func TestSelectCard(t *testing.T) {
+	g := startedGame(t, 2)
+	p := g.Players["p0"]
+	want := p.Hand[1]
+	handSize := len(p.Hand)
+
+	if err := SelectCard(g, "p0", 1); err != nil {
+		t.Fatalf("SelectCard: %v", err)
+	}
+
+	if len(p.Hand) != handSize-1 {
+		t.Errorf("hand has %d cards, want %d", len(p.Hand), handSize-1)
+	}
+	if len(p.PlayedCards) != 1 || p.PlayedCards[0] != want {
+		t.Errorf("played %v, want [%v]", p.PlayedCards, want)
+	}
+	if !p.HasSelected {
+		t.Error("HasSelected not set")
+	}
+}
+
+func TestSelectCardErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(g *models.Game)
+		playerID  string
+		cardIndex int
+		wantErr   error
+	}{
+		{"unknown player", nil, "nobody", 0, ErrPlayerNotFound},
+		{"negative index", nil, "p0", -1, ErrInvalidCardIndex},
+		{"index past hand", nil, "p0", 10, ErrInvalidCardIndex},
+		{"already selected", func(g *models.Game) { SelectCard(g, "p0", 0) }, "p0", 0, ErrNotYourTurn},
+		{"scoring", func(g *models.Game) { g.State = models.StateScoring }, "p0", 0, ErrGameNotPlaying},
+		{"finished", func(g *models.Game) { g.State = models.StateFinished }, "p0", 0, ErrGameNotPlaying},
+		{"finished with bad index", func(g *models.Game) { g.State = models.StateFinished }, "p0", 10, ErrGameNotPlaying},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startedGame(t, 2) // Ten cards each
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			before := len(g.Players["p0"].Hand)
+
+			if err := SelectCard(g, tt.playerID, tt.cardIndex); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SelectCard = %v, want %v", err, tt.wantErr)
+			}
+			if after := len(g.Players["p0"].Hand); after != before {
+				t.Errorf("hand changed from %d to %d cards on error", before, after)
+			}
+		})
+	}
+}
+
+func TestSelectCardWaiting(t *testing.T) {
+	// Hands are empty until the deal, so any index is out of range
+	for _, index := range []int{0, 3, -1} {
+		if err := SelectCard(newTestGame(2), "p0", index); !errors.Is(err, ErrGameNotPlaying) {
+			t.Errorf("SelectCard(%d) = %v, want %v", index, err, ErrGameNotPlaying)
+		}
+	}
+}
+
+func TestSelectCardDosa(t *testing.T) {
+	g := startedGame(t, 2)
+	p := g.Players["p0"]
+	p.Hand = []models.Card{{Type: models.Dosa}, {Type: models.Samosa}}
+
+	if err := SelectCard(g, "p0", 0); err != nil {
+		t.Fatalf("SelectCard: %v", err)
+	}
+	if !p.DosaActive {
+		t.Error("DosaActive not set after playing dosa")
+	}
+}
+
+func TestPassHands(t *testing.T) {
+	for players := 2; players <= 5; players++ {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			g := startedGame(t, players)
+			for id := range g.Players {
+				if err := SelectCard(g, id, 0); err != nil {
+					t.Fatalf("SelectCard(%s): %v", id, err)
+				}
+			}
+
+			before := make(map[string][]models.Card)
+			for id, p := range g.Players {
+				before[id] = p.Hand
+			}
+
+			if err := PassHands(g); err != nil {
+				t.Fatalf("PassHands: %v", err)
+			}
+
+			// Each hand moves one seat clockwise
+			for i := 0; i < players; i++ {
+				from := fmt.Sprintf("p%d", i)
+				to := fmt.Sprintf("p%d", (i+1)%players)
+				if got := g.Players[to].Hand; !sameCards(got, before[from]) {
+					t.Errorf("%s has %v, want %s's hand %v", to, got, from, before[from])
+				}
+			}
+
+			for id, p := range g.Players {
+				if p.HasSelected {
+					t.Errorf("%s still marked as selected", id)
+				}
+			}
+			if g.Turn != 2 {
+				t.Errorf("turn %d, want 2", g.Turn)
+			}
+		})
+	}
+}
+
+func TestPassHandsErrors(t *testing.T) {
+	g := startedGame(t, 3)
+	SelectCard(g, "p0", 0)
+	SelectCard(g, "p1", 0)
+
+	if err := PassHands(g); !errors.Is(err, ErrNotAllSelected) {
+		t.Errorf("PassHands = %v, want %v", err, ErrNotAllSelected)
+	}
+	if g.Turn != 1 {
+		t.Errorf("turn moved to %d on error", g.Turn)
+	}
+
+	if err := PassHands(newTestGame(0)); !errors.Is(err, ErrNoPlayers) {
+		t.Errorf("PassHands with no players = %v, want %v", err, ErrNoPlayers)
+	}
+}
+
+func TestPassHandsEndsRound(t *testing.T) {
+	g := startedGame(t, 2)
+	for turn := 1; turn < g.CardsPerHand(); turn++ {
+		for id := range g.Players {
+			SelectCard(g, id, 0)
+		}
+		if err := PassHands(g); err != nil {
+			t.Fatalf("PassHands: %v", err)
+		}
+	}
+
+	if g.Round != 2 || g.Turn != 1 || g.State != models.StatePlaying {
+		t.Fatalf("round %d turn %d state %s, want round 2 turn 1 playing", g.Round, g.Turn, g.State)
+	}
+	for id, p := range g.Players {
+		if len(p.RoundScores) != 1 {
+			t.Errorf("%s has %d round scores, want 1", id, len(p.RoundScores))
+		}
+		if len(p.Hand) != g.CardsPerHand() {
+			t.Errorf("%s was dealt %d cards, want %d", id, len(p.Hand), g.CardsPerHand())
+		}
+		for _, card := range p.PlayedCards {
+			if card.Type != models.GurabJamun {
+				t.Errorf("%s kept %s from round 1", id, card.Type)
+			}
+		}
+	}
+}
+
+func TestAutoSelect(t *testing.T) {
+	g := startedGame(t, 2)
+	if err := AutoSelect(g, "p0"); err != nil {
+		t.Fatalf("AutoSelect: %v", err)
+	}
+	if !g.Players["p0"].HasSelected {
+		t.Error("HasSelected not set")
+	}
+
+	if err := AutoSelect(g, "nobody"); !errors.Is(err, ErrPlayerNotFound) {
+		t.Errorf("AutoSelect(unknown) = %v, want %v", err, ErrPlayerNotFound)
+	}
+
+	g.Players["p1"].Hand = nil
+	if err := AutoSelect(g, "p1"); !errors.Is(err, ErrInvalidCardIndex) {
+		t.Errorf("AutoSelect(empty hand) = %v, want %v", err, ErrInvalidCardIndex)
+	}
+}
+
+func TestSelectForBots(t *testing.T) {
+	g := startedGame(t, 3)
+	g.Players["p1"].IsBot = true
+	g.Players["p2"].IsBot = true
+
+	SelectForBots(g)
+
+	if g.Players["p0"].HasSelected {
+		t.Error("human player was picked for")
+	}
+	if !g.Players["p1"].HasSelected || !g.Players["p2"].HasSelected {
+		t.Error("bots did not pick")
+	}
+}
+
+// sameCards reports whether two hands hold the same cards in the same order
+func sameCards(a, b []models.Card) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStartGameNamesPlayerLimits(t *testing.T) {
+	g := newTestGame(2)
+	g.MinPlayers, g.MaxPlayers = 3, 4
+
+	err := StartGame(g)
+	if !errors.Is(err, ErrCannotStart) {
+		t.Fatalf("StartGame = %v, want %v", err, ErrCannotStart)
+	}
+	if want := "cannot start game: need 3-4 players"; err.Error() != want {
+		t.Errorf("error %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"sort"
+)
+
+// MajorityRule configures a "most / second most / least" scoring category
+type MajorityRule struct {
+	Awards            []int // Points for most, second most, ... (split between tied players)
+	Penalty           int   // Points lost by the player(s) with the least (0 disables)
+	PenaltyMinPlayers int   // Minimum number of players before the penalty applies
+	CountZero         bool  // Whether players with a zero count can win awards
+	NoScoreIfAllTied  bool  // Whether a tie between every player scores nothing
+}
+
+// ChaiRule awards 6 points for most chai icons and 3 for second most
+var ChaiRule = MajorityRule{
+	Awards: []int{6, 3},
+}
+
+// GulabJamunRule awards 6 points for most gulab jamun and takes 6 from least
+var GulabJamunRule = MajorityRule{
+	Awards:            []int{6},
+	Penalty:           6,
+	PenaltyMinPlayers: 3,
+	CountZero:         true,
+	NoScoreIfAllTied:  true,
+}
+
+// ScoreMajority returns the points each player earns under a majority rule.
+//
+// Tied players split a tier's points evenly, rounding down. A tie also uses up
+// the tiers below it: if two players tie for most, nobody scores second most.
+// The penalty only applies when the least count is below the most count.
+func ScoreMajority(counts map[string]int, rule MajorityRule) map[string]int {
+	points := make(map[string]int)
+
+	type entry struct {
+		playerID string
+		count    int
+	}
+
+	entries := make([]entry, 0, len(counts))
+	for id, count := range counts {
+		entries = append(entries, entry{id, count})
+	}
+	if len(entries) == 0 {
+		return points
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].playerID < entries[j].playerID
+	})
+
+	most := entries[0].count
+	least := entries[len(entries)-1].count
+	if most == least && rule.NoScoreIfAllTied {
+		return points
+	}
+
+	// Award tiers from the top down, one group of tied players at a time
+	place := 0
+	for start := 0; start < len(entries) && place < len(rule.Awards); {
+		end := start
+		for end < len(entries) && entries[end].count == entries[start].count {
+			end++
+		}
+
+		if entries[start].count > 0 || rule.CountZero {
+			share := rule.Awards[place] / (end - start)
+			for _, e := range entries[start:end] {
+				points[e.playerID] += share
+			}
+		}
+
+		place += end - start
+		start = end
+	}
+
+	// Penalise the least, split between tied players
+	if rule.Penalty != 0 && least < most && len(entries) >= rule.PenaltyMinPlayers {
+		leastPlayers := []string{}
+		for _, e := range entries {
+			if e.count == least {
+				leastPlayers = append(leastPlayers, e.playerID)
+			}
+		}
+
+		share := rule.Penalty / len(leastPlayers)
+		for _, playerID := range leastPlayers {
+			points[playerID] -= share
+		}
+	}
+
+	return points
+}
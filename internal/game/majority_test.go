@@ -0,0 +1,109 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreMajority(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[string]int
+		rule   MajorityRule
+		want   map[string]int
+	}{
+		{
+			name:   "single winner and runner-up",
+			counts: map[string]int{"a": 5, "b": 3, "c": 1},
+			rule:   ChaiRule,
+			want:   map[string]int{"a": 6, "b": 3},
+		},
+		{
+			name:   "tie for first uses up second",
+			counts: map[string]int{"a": 4, "b": 4, "c": 2},
+			rule:   ChaiRule,
+			want:   map[string]int{"a": 3, "b": 3},
+		},
+		{
+			name:   "tie for second splits rounding down",
+			counts: map[string]int{"a": 5, "b": 2, "c": 2},
+			rule:   ChaiRule,
+			want:   map[string]int{"a": 6, "b": 1, "c": 1},
+		},
+		{
+			name:   "four-way tie for first rounds down",
+			counts: map[string]int{"a": 3, "b": 3, "c": 3, "d": 3},
+			rule:   ChaiRule,
+			want:   map[string]int{"a": 1, "b": 1, "c": 1, "d": 1},
+		},
+		{
+			name:   "zero counts win nothing without CountZero",
+			counts: map[string]int{"a": 2, "b": 0, "c": 0},
+			rule:   ChaiRule,
+			want:   map[string]int{"a": 6},
+		},
+		{
+			name:   "nobody has any",
+			counts: map[string]int{"a": 0, "b": 0},
+			rule:   ChaiRule,
+			want:   map[string]int{},
+		},
+		{
+			name:   "no players",
+			counts: map[string]int{},
+			rule:   GulabJamunRule,
+			want:   map[string]int{},
+		},
+		{
+			name:   "most and least",
+			counts: map[string]int{"a": 4, "b": 2, "c": 1},
+			rule:   GulabJamunRule,
+			want:   map[string]int{"a": 6, "c": -6},
+		},
+		{
+			name:   "penalty split between tied last places",
+			counts: map[string]int{"a": 4, "b": 1, "c": 1},
+			rule:   GulabJamunRule,
+			want:   map[string]int{"a": 6, "b": -3, "c": -3},
+		},
+		{
+			name:   "penalty split rounds down",
+			counts: map[string]int{"a": 4, "b": 0, "c": 0, "d": 0, "e": 0},
+			rule:   GulabJamunRule,
+			want:   map[string]int{"a": 6, "b": -1, "c": -1, "d": -1, "e": -1},
+		},
+		{
+			name:   "award split between tied first places",
+			counts: map[string]int{"a": 3, "b": 3, "c": 0},
+			rule:   GulabJamunRule,
+			want:   map[string]int{"a": 3, "b": 3, "c": -6},
+		},
+		{
+			name:   "no penalty below the minimum player count",
+			counts: map[string]int{"a": 3, "b": 0},
+			rule:   GulabJamunRule,
+			want:   map[string]int{"a": 6},
+		},
+		{
+			name:   "everyone tied scores nothing",
+			counts: map[string]int{"a": 2, "b": 2, "c": 2},
+			rule:   GulabJamunRule,
+			want:   map[string]int{},
+		},
+		{
+			name:   "everyone tied still splits without NoScoreIfAllTied",
+			counts: map[string]int{"a": 2, "b": 2, "c": 2},
+			rule:   MajorityRule{Awards: []int{6}, Penalty: 6, PenaltyMinPlayers: 3},
+			want:   map[string]int{"a": 2, "b": 2, "c": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScoreMajority(tt.counts, tt.rule)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScoreMajority(%v) = %v, want %v", tt.counts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// cardCounts counts cards by type and value
+func cardCounts(cards []models.Card) map[models.Card]int {
+	counts := make(map[models.Card]int)
+	for _, card := range cards {
+		counts[card]++
+	}
+	return counts
+}
+
+// equalCounts reports whether two card counts match
+func equalCounts(a, b map[models.Card]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for card, n := range a {
+		if b[card] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// checkConservation checks that the deck, hands and cards played this round
+// make up exactly one full deck. carried holds how many cards each player
+// brought into the round, which were dealt from an earlier deck.
+func checkConservation(game *models.Game, carried map[string]int) error {
+	all := append([]models.Card(nil), game.Deck...)
+	for id, p := range game.Players {
+		all = append(all, p.Hand...)
+		all = append(all, p.PlayedCards[carried[id]:]...)
+	}
+
+	if got, want := cardCounts(all), cardCounts(CreateDeck()); !equalCounts(got, want) {
+		return fmt.Errorf("round %d turn %d: cards in play %v, want %v", game.Round, game.Turn, got, want)
+	}
+	return nil
+}
+
+// playGame plays a whole game with random picks, checking that no card is
+// created or lost on any turn
+func playGame(players int, seed int64) error {
+	rng := rand.New(rand.NewSource(seed))
+	g := newTestGame(players)
+	if err := StartGame(g); err != nil {
+		return err
+	}
+
+	carried := make(map[string]int)
+	round := g.Round
+	for turns := 0; g.State == models.StatePlaying; turns++ {
+		if turns > 3*10 {
+			return fmt.Errorf("game still playing after %d turns", turns)
+		}
+		if err := checkConservation(g, carried); err != nil {
+			return err
+		}
+
+		for id, p := range g.Players {
+			if err := SelectCard(g, id, rng.Intn(len(p.Hand))); err != nil {
+				return fmt.Errorf("SelectCard(%s): %w", id, err)
+			}
+		}
+		if err := PassHands(g); err != nil {
+			return fmt.Errorf("PassHands: %w", err)
+		}
+
+		// A new round deals a fresh deck; only kept puddings carry over
+		if g.Round != round {
+			round = g.Round
+			for id, p := range g.Players {
+				carried[id] = len(p.PlayedCards)
+				for _, card := range p.PlayedCards {
+					if card.Type != models.GurabJamun {
+						return fmt.Errorf("%s kept %s into round %d", id, card.Type, round)
+					}
+				}
+			}
+		}
+	}
+
+	if g.State != models.StateFinished || g.Round != 3 {
+		return fmt.Errorf("game ended in state %s round %d", g.State, g.Round)
+	}
+	for id, p := range g.Players {
+		if len(p.RoundScores) != 3 {
+			return fmt.Errorf("%s has %d round scores, want 3", id, len(p.RoundScores))
+		}
+	}
+	return nil
+}
+
+func TestCardConservation(t *testing.T) {
+	for players := 2; players <= 5; players++ {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			check := func(seed int64) bool {
+				if err := playGame(players, seed); err != nil {
+					t.Log(err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(check, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRoundScoresAddUp(t *testing.T) {
+	check := func(seed int64, extra uint8) bool {
+		players := 2 + int(extra)%4
+		rng := rand.New(rand.NewSource(seed))
+		g := newTestGame(players)
+		StartGame(g)
+
+		var finalRound map[string][]models.Card
+		for g.State == models.StatePlaying {
+			for id, p := range g.Players {
+				SelectCard(g, id, rng.Intn(len(p.Hand)))
+			}
+			if g.Round == 3 {
+				finalRound = make(map[string][]models.Card)
+				for id, p := range g.Players {
+					finalRound[id] = p.PlayedCards
+				}
+			}
+			PassHands(g)
+		}
+
+		// The total is the round scores plus the pudding bonus, which
+		// FinalScoring works out from the last round's tableaux
+		puddings := make(map[string]int)
+		for id, played := range finalRound {
+			puddings[id] = 0
+			for _, card := range played {
+				if card.Type == models.GurabJamun {
+					puddings[id]++
+				}
+			}
+		}
+		bonus := ScoreMajority(puddings, GulabJamunRule)
+
+		for id, p := range g.Players {
+			sum := 0
+			for _, s := range p.RoundScores {
+				sum += s
+			}
+			if p.Score != sum+bonus[id] {
+				t.Logf("%s scored %d, want %d round points + %d bonus", id, p.Score, sum, bonus[id])
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
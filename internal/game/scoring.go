@@ -64,12 +64,7 @@ func ScoreRound(game *models.Game) {
 
 // scoreChaiForPlayers awards points for most/second most chai icons
 func scoreChaiForPlayers(game *models.Game) {
-	type playerChai struct {
-		playerID string
-		icons    int
-	}
-
-	chaiCounts := []playerChai{}
+	chaiCounts := make(map[string]int)
 	for id, player := range game.Players {
 		icons := 0
 		for _, card := range player.PlayedCards {
@@ -77,76 +72,20 @@ func scoreChaiForPlayers(game *models.Game) {
 				icons += card.Value
 			}
 		}
-		if icons > 0 {
-			chaiCounts = append(chaiCounts, playerChai{id, icons})
-		}
-	}
-
-	if len(chaiCounts) == 0 {
-		return
-	}
-
-	// Sort by icons descending
-	for i := 0; i < len(chaiCounts); i++ {
-		for j := i + 1; j < len(chaiCounts); j++ {
-			if chaiCounts[j].icons > chaiCounts[i].icons {
-				chaiCounts[i], chaiCounts[j] = chaiCounts[j], chaiCounts[i]
-			}
-		}
+		chaiCounts[id] = icons
 	}
 
-	// Award points for most
-	mostIcons := chaiCounts[0].icons
-	mostPlayers := []string{}
-	for _, pc := range chaiCounts {
-		if pc.icons == mostIcons {
-			mostPlayers = append(mostPlayers, pc.playerID)
-		}
-	}
-
-	pointsPerPlayer := 6 / len(mostPlayers)
-	for _, playerID := range mostPlayers {
-		if len(game.Players[playerID].RoundScores) > 0 {
-			game.Players[playerID].RoundScores[len(game.Players[playerID].RoundScores)-1] += pointsPerPlayer
-		}
-	}
-
-	// Award points for second most (if not tied for first)
-	if len(chaiCounts) > len(mostPlayers) {
-		secondMostIcons := 0
-		for _, pc := range chaiCounts {
-			if pc.icons < mostIcons {
-				secondMostIcons = pc.icons
-				break
-			}
-		}
-
-		if secondMostIcons > 0 {
-			secondPlayers := []string{}
-			for _, pc := range chaiCounts {
-				if pc.icons == secondMostIcons {
-					secondPlayers = append(secondPlayers, pc.playerID)
-				}
-			}
-
-			pointsPerPlayer := 3 / len(secondPlayers)
-			for _, playerID := range secondPlayers {
-				if len(game.Players[playerID].RoundScores) > 0 {
-					game.Players[playerID].RoundScores[len(game.Players[playerID].RoundScores)-1] += pointsPerPlayer
-				}
-			}
+	for playerID, points := range ScoreMajority(chaiCounts, ChaiRule) {
+		roundScores := game.Players[playerID].RoundScores
+		if len(roundScores) > 0 {
+			roundScores[len(roundScores)-1] += points
 		}
 	}
 }
 
 // FinalScoring calculates Gulab Jamun (pudding) points at game end
 func FinalScoring(game *models.Game) {
-	type playerPudding struct {
-		playerID string
-		count    int
-	}
-
-	puddingCounts := []playerPudding{}
+	puddingCounts := make(map[string]int)
 	for id, player := range game.Players {
 		count := 0
 		for _, card := range player.PlayedCards {
@@ -154,39 +93,10 @@ func FinalScoring(game *models.Game) {
 				count++
 			}
 		}
-		puddingCounts = append(puddingCounts, playerPudding{id, count})
-	}
-
-	if len(puddingCounts) == 0 {
-		return
-	}
-
-	// Sort by count
-	for i := 0; i < len(puddingCounts); i++ {
-		for j := i + 1; j < len(puddingCounts); j++ {
-			if puddingCounts[j].count > puddingCounts[i].count {
-				puddingCounts[i], puddingCounts[j] = puddingCounts[j], puddingCounts[i]
-			}
-		}
+		puddingCounts[id] = count
 	}
 
-	// Most pudding gets +6
-	mostCount := puddingCounts[0].count
-	for _, pp := range puddingCounts {
-		if pp.count == mostCount {
-			game.Players[pp.playerID].Score += 6
-		}
-	}
-
-	// Least pudding gets -6 (only if 3+ players)
-	if len(game.Players) >= 3 {
-		leastCount := puddingCounts[len(puddingCounts)-1].count
-		if leastCount < mostCount {
-			for _, pp := range puddingCounts {
-				if pp.count == leastCount {
-					game.Players[pp.playerID].Score -= 6
-				}
-			}
-		}
+	for playerID, points := range ScoreMajority(puddingCounts, GulabJamunRule) {
+		game.Players[playerID].Score += points
 	}
 }
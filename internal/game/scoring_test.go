@@ -0,0 +1,169 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// cards builds a tableau of n cards of one type
+func cards(cardType models.CardType, n int) []models.Card {
+	c := make([]models.Card, n)
+	for i := range c {
+		c[i] = models.Card{Type: cardType}
+	}
+	return c
+}
+
+// chai builds chai cards with the given icon counts
+func chai(icons ...int) []models.Card {
+	c := make([]models.Card, len(icons))
+	for i, v := range icons {
+		c[i] = models.Card{Type: models.Chai, Value: v}
+	}
+	return c
+}
+
+// concat joins tableaux
+func concat(parts ...[]models.Card) []models.Card {
+	var all []models.Card
+	for _, p := range parts {
+		all = append(all, p...)
+	}
+	return all
+}
+
+func TestScoreRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		played []models.Card // p0's tableau; p1 plays nothing
+		want   int
+	}{
+		{"nothing", nil, 0},
+		{"one samosa", cards(models.Samosa, 1), 0},
+		{"samosa pair", cards(models.Samosa, 2), 5},
+		{"three samosa", cards(models.Samosa, 3), 5},
+		{"two samosa pairs", cards(models.Samosa, 4), 10},
+		{"one biryani", cards(models.Biryani, 1), 1},
+		{"two biryani", cards(models.Biryani, 2), 3},
+		{"three biryani", cards(models.Biryani, 3), 6},
+		{"four biryani", cards(models.Biryani, 4), 10},
+		{"five biryani", cards(models.Biryani, 5), 15},
+		{"seven biryani", cards(models.Biryani, 7), 15},
+		{"two paneer tikka", cards(models.PaneerTikka, 2), 0},
+		{"three paneer tikka", cards(models.PaneerTikka, 3), 10},
+		{"six paneer tikka", cards(models.PaneerTikka, 6), 20},
+		{"most chai", chai(1, 2), 6},
+		{"gulab jamun scores at the end", cards(models.GurabJamun, 3), 0},
+		{"dosa alone", cards(models.Dosa, 2), 0},
+		{"raita alone", cards(models.Raita, 2), 0},
+		{"mixed", concat(cards(models.Samosa, 2), cards(models.Biryani, 3), cards(models.PaneerTikka, 3), chai(3)), 5 + 6 + 10 + 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(2)
+			g.Players["p0"].PlayedCards = tt.played
+			g.Players["p0"].Score = 7
+
+			ScoreRound(g)
+
+			p0, p1 := g.Players["p0"], g.Players["p1"]
+			if len(p0.RoundScores) != 1 || p0.RoundScores[0] != tt.want {
+				t.Errorf("round scores %v, want [%d]", p0.RoundScores, tt.want)
+			}
+			if p0.Score != 7+tt.want {
+				t.Errorf("score %d, want %d", p0.Score, 7+tt.want)
+			}
+			if len(p1.RoundScores) != 1 || p1.RoundScores[0] != 0 {
+				t.Errorf("empty tableau scored %v", p1.RoundScores)
+			}
+		})
+	}
+}
+
+func TestScoreRoundChai(t *testing.T) {
+	tests := []struct {
+		name   string
+		played map[string][]models.Card
+		want   map[string]int
+	}{
+		{
+			name:   "most and second most",
+			played: map[string][]models.Card{"p0": chai(3, 1), "p1": chai(2), "p2": chai(1)},
+			want:   map[string]int{"p0": 6, "p1": 3, "p2": 0},
+		},
+		{
+			name:   "icons count, not cards",
+			played: map[string][]models.Card{"p0": chai(3), "p1": chai(1, 1), "p2": nil},
+			want:   map[string]int{"p0": 6, "p1": 3, "p2": 0},
+		},
+		{
+			name:   "tie for most",
+			played: map[string][]models.Card{"p0": chai(2), "p1": chai(2), "p2": chai(1)},
+			want:   map[string]int{"p0": 3, "p1": 3, "p2": 0},
+		},
+		{
+			name:   "tie for second",
+			played: map[string][]models.Card{"p0": chai(3), "p1": chai(1), "p2": chai(1)},
+			want:   map[string]int{"p0": 6, "p1": 1, "p2": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(len(tt.played))
+			for id, played := range tt.played {
+				g.Players[id].PlayedCards = played
+			}
+
+			ScoreRound(g)
+
+			for id, want := range tt.want {
+				if got := g.Players[id].RoundScores; len(got) != 1 || got[0] != want {
+					t.Errorf("%s round scores %v, want [%d]", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFinalScoring(t *testing.T) {
+	tests := []struct {
+		name     string
+		puddings []int // Gulab jamun played by p0, p1, ...
+		want     []int // Score change
+	}{
+		{"most and least", []int{3, 1, 0}, []int{6, 0, -6}},
+		{"tie for most", []int{2, 2, 0}, []int{3, 3, -6}},
+		{"tie for least", []int{3, 0, 0}, []int{6, -3, -3}},
+		{"everyone tied", []int{2, 2, 2}, []int{0, 0, 0}},
+		{"nobody has any", []int{0, 0, 0}, []int{0, 0, 0}},
+		{"two players get no penalty", []int{2, 0}, []int{6, 0}},
+		{"five players", []int{4, 3, 2, 1, 0}, []int{6, 0, 0, 0, -6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(len(tt.puddings))
+			for i, n := range tt.puddings {
+				p := g.Players[seatID(i)]
+				p.PlayedCards = concat(cards(models.GurabJamun, n), cards(models.Samosa, 2))
+				p.Score = 10
+			}
+
+			FinalScoring(g)
+
+			for i, want := range tt.want {
+				if got := g.Players[seatID(i)].Score; got != 10+want {
+					t.Errorf("%s score %d, want %d", seatID(i), got, 10+want)
+				}
+			}
+		})
+	}
+}
+
+// seatID returns the ID newTestGame gives seat i
+func seatID(i int) string {
+	return "p" + string(rune('0'+i))
+}
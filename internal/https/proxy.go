@@ -0,0 +1,129 @@
+package https
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Proxies is the set of reverse proxies whose X-Forwarded-* headers are
+// believed. Headers from anyone else are ignored, since clients can send
+// whatever they like.
+type Proxies struct {
+	prefixes []netip.Prefix
+}
+
+// secureKey marks requests a trusted proxy received over HTTPS
+type secureKey struct{}
+
+// ParseProxies parses IP addresses and CIDR ranges
+func ParseProxies(entries []string) (*Proxies, error) {
+	p := &Proxies{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.prefixes = append(p.prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", entry)
+		}
+		p.prefixes = append(p.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return p, nil
+}
+
+// Trusted reports whether addr belongs to a trusted proxy
+func (p *Proxies) Trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler applies X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host
+// from trusted proxies before calling next. RemoteAddr becomes the client
+// address, so rate limits and logs see the player rather than the proxy.
+func (p *Proxies) Handler(next http.Handler) http.Handler {
+	if len(p.prefixes) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer, ok := remoteAddr(r)
+		if !ok || !p.Trusted(peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		if client, ok := p.clientAddr(r.Header.Values("X-Forwarded-For")); ok {
+			r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+		}
+		if host := lastValue(r.Header.Values("X-Forwarded-Host")); host != "" {
+			r.Host = host
+		}
+		if strings.EqualFold(lastValue(r.Header.Values("X-Forwarded-Proto")), "https") {
+			r = r.WithContext(context.WithValue(r.Context(), secureKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddr walks X-Forwarded-For from the right, skipping our own proxies,
+// and returns the first address they didn't add themselves. Nothing left of
+// a hop that doesn't parse can be believed, so the walk stops at the last
+// good hop before it.
+func (p *Proxies) clientAddr(headers []string) (netip.Addr, bool) {
+	var hops []string
+	for _, header := range headers {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !p.Trusted(client) {
+			break
+		}
+	}
+	return client, client.IsValid()
+}
+
+// IsSecure reports whether the client connected over HTTPS, either directly
+// or through a trusted proxy
+func IsSecure(r *http.Request) bool {
+	secure, _ := r.Context().Value(secureKey{}).(bool)
+	return r.TLS != nil || secure
+}
+
+// remoteAddr parses the IP of the connection's peer
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr, err == nil
+}
+
+// lastValue returns the right-most entry of a comma-separated header, which
+// is the one the nearest proxy added
+func lastValue(headers []string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	values := strings.Split(headers[len(headers)-1], ",")
+	return strings.TrimSpace(values[len(values)-1])
+}
@@ -0,0 +1,146 @@
+package https
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serve sends a request from peer with the given X-Forwarded-* headers
+// through the proxies' handler and returns the request next saw
+func serve(t *testing.T, p *Proxies, peer string, headers map[string][]string) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "http://game.example/", nil)
+	req.RemoteAddr = peer
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	var seen *http.Request
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	return seen
+}
+
+func TestHandlerClientAddr(t *testing.T) {
+	p, err := ParseProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseProxies: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		peer           string
+		forwardedFor   []string
+		wantRemoteAddr string
+	}{
+		{"untrusted peer is kept", "203.0.113.9:5000", []string{"198.51.100.7"}, "203.0.113.9:5000"},
+		{"one trusted hop", "10.0.0.1:5000", []string{"198.51.100.7"}, "198.51.100.7:0"},
+		{"several trusted hops", "10.0.0.1:5000", []string{"198.51.100.7, 10.0.0.3, 192.0.2.1"}, "198.51.100.7:0"},
+		{"hops across headers", "10.0.0.1:5000", []string{"198.51.100.7, 10.0.0.3", "192.0.2.1"}, "198.51.100.7:0"},
+		{"spoofed leftmost entry", "10.0.0.1:5000", []string{"1.1.1.1, 198.51.100.7, 10.0.0.3"}, "198.51.100.7:0"},
+		{"every hop trusted", "10.0.0.1:5000", []string{"10.0.0.4, 10.0.0.3"}, "10.0.0.4:0"},
+		{"mapped address", "10.0.0.1:5000", []string{"::ffff:198.51.100.7"}, "198.51.100.7:0"},
+		{"garbled hop stops at last good hop", "10.0.0.1:5000", []string{"198.51.100.7, bogus, 10.0.0.3"}, "10.0.0.3:0"},
+		{"garbled hop after untrusted hop", "10.0.0.1:5000", []string{"bogus, 198.51.100.7, 10.0.0.3"}, "198.51.100.7:0"},
+		{"nothing parses", "10.0.0.1:5000", []string{"bogus"}, "10.0.0.1:5000"},
+		{"no header", "10.0.0.1:5000", nil, "10.0.0.1:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := serve(t, p, tt.peer, map[string][]string{"X-Forwarded-For": tt.forwardedFor})
+			if r.RemoteAddr != tt.wantRemoteAddr {
+				t.Errorf("RemoteAddr %q, want %q", r.RemoteAddr, tt.wantRemoteAddr)
+			}
+		})
+	}
+}
+
+func TestHandlerHostAndProto(t *testing.T) {
+	p, err := ParseProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatalf("ParseProxies: %v", err)
+	}
+	headers := map[string][]string{
+		"X-Forwarded-Host":  {"spoofed.example, tiffin.example"},
+		"X-Forwarded-Proto": {"https"},
+	}
+
+	trusted := serve(t, p, "10.0.0.1:5000", headers)
+	if trusted.Host != "tiffin.example" || !IsSecure(trusted) {
+		t.Errorf("trusted proxy: host %q secure %v, want tiffin.example and true", trusted.Host, IsSecure(trusted))
+	}
+
+	untrusted := serve(t, p, "203.0.113.9:5000", headers)
+	if untrusted.Host != "game.example" || IsSecure(untrusted) {
+		t.Errorf("untrusted peer: host %q secure %v, want game.example and false", untrusted.Host, IsSecure(untrusted))
+	}
+}
+
+func TestRedirectAndHSTS(t *testing.T) {
+	p, err := ParseProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatalf("ParseProxies: %v", err)
+	}
+	app := HSTS(time.Hour, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name         string
+		httpsAddr    string
+		peer         string
+		proto        string
+		wantStatus   int
+		wantLocation string
+		wantHSTS     bool
+	}{
+		{"plain HTTP", ":443", "203.0.113.9:5000", "", http.StatusPermanentRedirect, "https://game.example/play?x=1", false},
+		{"port kept", ":8443", "203.0.113.9:5000", "", http.StatusPermanentRedirect, "https://game.example:8443/play?x=1", false},
+		{"https from untrusted peer", ":443", "203.0.113.9:5000", "https", http.StatusPermanentRedirect, "https://game.example/play?x=1", false},
+		{"http from trusted proxy", ":443", "10.0.0.1:5000", "http", http.StatusPermanentRedirect, "https://game.example/play?x=1", false},
+		{"https from trusted proxy", ":443", "10.0.0.1:5000", "https", http.StatusOK, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://game.example:8080/play?x=1", nil)
+			req.RemoteAddr = tt.peer
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+			p.Handler(Redirect(tt.httpsAddr, app)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location %q, want %q", got, tt.wantLocation)
+			}
+			if got := rec.Header().Get("Strict-Transport-Security") != ""; got != tt.wantHSTS {
+				t.Errorf("HSTS header %v, want %v", got, tt.wantHSTS)
+			}
+		})
+	}
+}
+
+func TestHSTSDirectTLS(t *testing.T) {
+	h := HSTS(24*time.Hour, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://game.example/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=86400" {
+		t.Errorf("HSTS over TLS %q, want max-age=86400", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://game.example/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS over plain HTTP %q, want none", got)
+	}
+}
@@ -0,0 +1,215 @@
+// Package jsonpatch computes and applies the subset of JSON Patch (RFC 6902)
+// needed to stream state updates: add, remove and replace.
+//
+// Documents are generic JSON values as produced by encoding/json decoding
+// into interface{}: map[string]interface{}, []interface{}, string, float64,
+// bool and nil.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation names
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Operation is one JSON Patch step
+type Operation struct {
+	Op    string      `json:"op"`    // add, remove or replace
+	Path  string      `json:"path"`  // JSON Pointer to the target
+	Value interface{} `json:"value"` // New value; null for remove
+}
+
+// ErrBadPath is returned when a patch path does not fit the document
+var ErrBadPath = errors.New("jsonpatch: path does not match document")
+
+// ToValue converts v to a generic JSON value by round-tripping it through
+// encoding/json
+func ToValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Diff returns the operations that turn from into to
+func Diff(from, to interface{}) []Operation {
+	return diff(nil, "", from, to)
+}
+
+func diff(ops []Operation, path string, from, to interface{}) []Operation {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		b, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key := range a {
+			if _, exists := b[key]; !exists {
+				ops = append(ops, Operation{Op: OpRemove, Path: path + "/" + escape(key)})
+			}
+		}
+		for key, value := range b {
+			if old, exists := a[key]; exists {
+				ops = diff(ops, path+"/"+escape(key), old, value)
+			} else {
+				ops = append(ops, Operation{Op: OpAdd, Path: path + "/" + escape(key), Value: value})
+			}
+		}
+		return ops
+
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		common := min(len(a), len(b))
+		for i := 0; i < common; i++ {
+			ops = diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+		}
+		// Remove from the end so earlier indexes stay valid
+		for i := len(a) - 1; i >= common; i-- {
+			ops = append(ops, Operation{Op: OpRemove, Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := common; i < len(b); i++ {
+			ops = append(ops, Operation{Op: OpAdd, Path: path + "/-", Value: b[i]})
+		}
+		return ops
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		ops = append(ops, Operation{Op: OpReplace, Path: path, Value: to})
+	}
+	return ops
+}
+
+// Apply returns doc with the operations applied. Containers along each path
+// are modified in place, so callers should not share doc.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	for _, op := range ops {
+		var err error
+		if doc, err = apply(doc, op); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	if op.Path == "" {
+		if op.Op == OpRemove {
+			return nil, nil
+		}
+		return op.Value, nil
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return nil, ErrBadPath
+	}
+
+	tokens := strings.Split(op.Path[1:], "/")
+	for i := range tokens {
+		tokens[i] = unescape(tokens[i])
+	}
+	return update(doc, tokens, op)
+}
+
+// update applies op at tokens below node and returns the new node
+func update(node interface{}, tokens []string, op Operation) (interface{}, error) {
+	key, last := tokens[0], len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if !last {
+			child, exists := n[key]
+			if !exists {
+				return nil, ErrBadPath
+			}
+			updated, err := update(child, tokens[1:], op)
+			if err != nil {
+				return nil, err
+			}
+			n[key] = updated
+			return n, nil
+		}
+
+		switch op.Op {
+		case OpAdd:
+			n[key] = op.Value
+		case OpReplace, OpRemove:
+			if _, exists := n[key]; !exists {
+				return nil, ErrBadPath
+			}
+			if op.Op == OpRemove {
+				delete(n, key)
+			} else {
+				n[key] = op.Value
+			}
+		default:
+			return nil, fmt.Errorf("jsonpatch: unsupported op %q", op.Op)
+		}
+		return n, nil
+
+	case []interface{}:
+		if last && key == "-" && op.Op == OpAdd {
+			return append(n, op.Value), nil
+		}
+
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(n) || (index == len(n) && op.Op != OpAdd) {
+			return nil, ErrBadPath
+		}
+
+		if !last {
+			if index == len(n) {
+				return nil, ErrBadPath
+			}
+			updated, err := update(n[index], tokens[1:], op)
+			if err != nil {
+				return nil, err
+			}
+			n[index] = updated
+			return n, nil
+		}
+
+		switch op.Op {
+		case OpAdd:
+			n = append(n, nil)
+			copy(n[index+1:], n[index:])
+			n[index] = op.Value
+		case OpReplace:
+			n[index] = op.Value
+		case OpRemove:
+			n = append(n[:index], n[index+1:]...)
+		default:
+			return nil, fmt.Errorf("jsonpatch: unsupported op %q", op.Op)
+		}
+		return n, nil
+	}
+
+	return nil, ErrBadPath
+}
+
+// escape encodes a key as a JSON Pointer token
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// unescape decodes a JSON Pointer token
+func unescape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,115 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// parse decodes a JSON document into a generic value
+func parse(t *testing.T, doc string) interface{} {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("parse %s: %v", doc, err)
+	}
+	return v
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		wantOps  int // -1 skips the check
+	}{
+		{"unchanged", `{"a":1,"b":[1,2]}`, `{"a":1,"b":[1,2]}`, 0},
+		{"replace scalar", `{"a":1}`, `{"a":2}`, 1},
+		{"add key", `{"a":1}`, `{"a":1,"b":"x"}`, 1},
+		{"remove key", `{"a":1,"b":"x"}`, `{"a":1}`, 1},
+		{"value to null", `{"a":1}`, `{"a":null}`, 1},
+		{"null to value", `{"a":null}`, `{"a":{"b":1}}`, 1},
+		{"add null key", `{}`, `{"a":null}`, 1},
+		{"remove null key", `{"a":null}`, `{}`, 1},
+		{"nested object", `{"p":{"q":{"r":1,"s":2}}}`, `{"p":{"q":{"r":1,"s":3,"t":[]}}}`, 2},
+		{"array grows", `{"a":[1]}`, `{"a":[1,2,3]}`, 2},
+		{"array grows from empty", `{"a":[]}`, `{"a":[{"x":1}]}`, 1},
+		{"array shrinks", `{"a":[1,2,3,4]}`, `{"a":[1,5]}`, 3},
+		{"array shrinks to empty", `{"a":[1,2]}`, `{"a":[]}`, 2},
+		{"array of objects", `[{"id":1,"n":"a"},{"id":2,"n":"b"}]`, `[{"id":1,"n":"c"}]`, 2},
+		{"escaped slash", `{"a/b":1}`, `{"a/b":2,"c/d":[]}`, 2},
+		{"escaped tilde", `{"m~n":{"~1":1}}`, `{"m~n":{"~1":2,"~0":3}}`, 2},
+		{"object to array", `{"a":{"b":1}}`, `{"a":[1]}`, 1},
+		{"replace root", `[1,2]`, `"done"`, 1},
+		{"game state", `{"state":"playing","players":[{"id":"p1","hand":[{"type":"dosa"}],"score":0}]}`,
+			`{"state":"scoring","players":[{"id":"p1","hand":[],"score":4,"played":[{"type":"dosa"}]}]}`, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := Diff(parse(t, tt.from), parse(t, tt.to))
+			if tt.wantOps >= 0 && len(ops) != tt.wantOps {
+				t.Errorf("%d operations, want %d: %+v", len(ops), tt.wantOps, ops)
+			}
+
+			// Patches travel as JSON, so apply what a client would decode
+			data, err := json.Marshal(ops)
+			if err != nil {
+				t.Fatalf("marshal ops: %v", err)
+			}
+			var decoded []Operation
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unmarshal ops: %v", err)
+			}
+
+			got, err := Apply(parse(t, tt.from), decoded)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if want := parse(t, tt.to); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply(from, Diff(from, to)) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEscapedPaths(t *testing.T) {
+	ops := Diff(parse(t, `{}`), parse(t, `{"a/b~c":1}`))
+	if len(ops) != 1 || ops[0].Path != "/a~1b~0c" {
+		t.Fatalf("ops %+v, want one add at /a~1b~0c", ops)
+	}
+
+	// "~01" is "~1" escaped, not "/"
+	got, err := Apply(parse(t, `{"~1":1,"/":2}`), []Operation{{Op: OpRemove, Path: "/~01"}})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := parse(t, `{"/":2}`); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestApplyBadPath(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		op   Operation
+	}{
+		{"missing key", `{"a":1}`, Operation{Op: OpReplace, Path: "/b", Value: 1}},
+		{"remove missing key", `{"a":1}`, Operation{Op: OpRemove, Path: "/b"}},
+		{"missing parent", `{"a":1}`, Operation{Op: OpAdd, Path: "/b/c", Value: 1}},
+		{"index past end", `[1]`, Operation{Op: OpReplace, Path: "/1", Value: 2}},
+		{"bad index", `[1]`, Operation{Op: OpReplace, Path: "/x", Value: 2}},
+		{"into scalar", `{"a":1}`, Operation{Op: OpAdd, Path: "/a/b", Value: 2}},
+		{"no leading slash", `{"a":1}`, Operation{Op: OpReplace, Path: "a", Value: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(parse(t, tt.doc), []Operation{tt.op}); !errors.Is(err, ErrBadPath) {
+				t.Errorf("Apply = %v, want ErrBadPath", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package metrics implements in-process counters, gauges and histograms and
+// writes them in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies measured in seconds
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Registry holds metrics in the order they were registered
+type Registry struct {
+	metrics []metric
+	mu      sync.Mutex
+}
+
+// metric is anything that can write its samples
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// desc is a metric's name, help text and label names
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// sample writes one line; extra is an already formatted label pair or ""
+func (d desc) sample(w *bufio.Writer, suffix string, values []string, extra string, v float64) {
+	w.WriteString(d.name + suffix)
+
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, d.labels[i]+`="`+escapeLabel(value)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// Counter is a monotonically increasing value per set of label values
+type Counter struct {
+	desc
+	values map[string]*labelled
+	mu     sync.Mutex
+}
+
+// labelled is one series' label values and value
+type labelled struct {
+	labels []string
+	value  float64
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]*labelled),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series for the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series for the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: counter %s wants %d label values, got %d", c.name, len(c.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, exists := c.values[key]
+	if !exists {
+		s = &labelled{labels: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of the series for the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, exists := c.values[strings.Join(labelValues, "\xff")]; exists {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.header(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		c.sample(w, "", nil, "", 0)
+		return
+	}
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		c.sample(w, "", s.labels, "", s.value)
+	}
+}
+
+// GaugeFunc is a gauge whose series are read when metrics are written
+type GaugeFunc struct {
+	desc
+	read func() map[string]float64 // Label value -> gauge value
+}
+
+// GaugeFunc registers a gauge without labels whose value comes from fn
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(&GaugeFunc{
+		desc: desc{name: name, help: help, kind: "gauge"},
+		read: func() map[string]float64 { return map[string]float64{"": fn()} },
+	})
+}
+
+// GaugeVecFunc registers a gauge with one label whose series come from fn
+func (r *Registry) GaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(&GaugeFunc{
+		desc: desc{name: name, help: help, kind: "gauge", labels: []string{label}},
+		read: fn,
+	})
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.header(w)
+
+	values := g.read()
+	for _, key := range sortedKeys(values) {
+		if len(g.labels) == 0 {
+			g.sample(w, "", nil, "", values[key])
+		} else {
+			g.sample(w, "", []string{key}, "", values[key])
+		}
+	}
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	desc
+	bounds []float64 // Upper bounds, ascending
+	counts []uint64  // Per bucket, not cumulative; last is +Inf
+	sum    float64
+	count  uint64
+	mu     sync.Mutex
+}
+
+// Histogram registers a histogram with the given bucket upper bounds
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+
+	h := &Histogram{
+		desc:   desc{name: name, help: help, kind: "histogram"},
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.header(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		h.sample(w, "_bucket", nil, `le="`+formatFloat(bound)+`"`, float64(cumulative))
+	}
+	h.sample(w, "_bucket", nil, `le="+Inf"`, float64(h.count))
+	h.sample(w, "_sum", nil, "", h.sum)
+	h.sample(w, "_count", nil, "", float64(h.count))
+}
+
+// sortedKeys returns a map's keys in order so output is stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a sample value the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp escapes help text
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// text returns the registry's exposition output
+func text(t *testing.T, r *Registry) string {
+	t.Helper()
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	return b.String()
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe []float64
+		want    string
+	}{
+		{
+			name: "empty",
+			want: `h_bucket{le="1"} 0
+h_bucket{le="2.5"} 0
+h_bucket{le="+Inf"} 0
+h_sum 0
+h_count 0
+`,
+		},
+		{
+			name:    "bounds are inclusive",
+			observe: []float64{1, 2.5},
+			want: `h_bucket{le="1"} 1
+h_bucket{le="2.5"} 2
+h_bucket{le="+Inf"} 2
+h_sum 3.5
+h_count 2
+`,
+		},
+		{
+			name:    "just above a bound",
+			observe: []float64{1.0000001},
+			want: `h_bucket{le="1"} 0
+h_bucket{le="2.5"} 1
+h_bucket{le="+Inf"} 1
+h_sum 1.0000001
+h_count 1
+`,
+		},
+		{
+			name:    "overflow only in +Inf",
+			observe: []float64{0.5, 100},
+			want: `h_bucket{le="1"} 1
+h_bucket{le="2.5"} 1
+h_bucket{le="+Inf"} 2
+h_sum 100.5
+h_count 2
+`,
+		},
+		{
+			name:    "negative and zero",
+			observe: []float64{-3, 0},
+			want: `h_bucket{le="1"} 2
+h_bucket{le="2.5"} 2
+h_bucket{le="+Inf"} 2
+h_sum -3
+h_count 2
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			h := r.Histogram("h", "A histogram.", []float64{2.5, 1}) // Unsorted on purpose
+			for _, v := range tt.observe {
+				h.Observe(v)
+			}
+
+			want := "# HELP h A histogram.\n# TYPE h histogram\n" + tt.want
+			if got := text(t, r); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+			if h.Count() != uint64(len(tt.observe)) {
+				t.Errorf("Count = %d, want %d", h.Count(), len(tt.observe))
+			}
+		})
+	}
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	plain := r.Counter("plain_total", "No labels.")
+	byType := r.Counter("by_type_total", "With a label.", "type")
+
+	byType.Inc("b")
+	byType.Add(2.5, "a")
+	byType.Inc("a")
+
+	want := `# HELP plain_total No labels.
+# TYPE plain_total counter
+plain_total 0
+# HELP by_type_total With a label.
+# TYPE by_type_total counter
+by_type_total{type="a"} 3.5
+by_type_total{type="b"} 1
+`
+	if got := text(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	plain.Inc()
+	if plain.Value() != 1 || byType.Value("a") != 3.5 || byType.Value("missing") != 0 {
+		t.Errorf("values %v %v %v, want 1 3.5 0", plain.Value(), byType.Value("a"), byType.Value("missing"))
+	}
+}
+
+func TestCounterPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(c *Counter)
+	}{
+		{"negative", func(c *Counter) { c.Add(-1, "x") }},
+		{"missing label", func(c *Counter) { c.Inc() }},
+		{"extra label", func(c *Counter) { c.Inc("x", "y") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewRegistry().Counter("c_total", "Counter.", "label")
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn(c)
+		})
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`plain`, `plain`},
+		{`back\slash`, `back\\slash`},
+		{`"quoted"`, `\"quoted\"`},
+		{"two\nlines", `two\nlines`},
+		{`\"` + "\n", `\\\"\n`},
+		{`ünïcode ✓`, `ünïcode ✓`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			r := NewRegistry()
+			r.Counter("c_total", "Counter.", "label").Inc(tt.value)
+
+			want := `c_total{label="` + tt.want + `"} 1` + "\n"
+			if got := text(t, r); !strings.HasSuffix(got, want) {
+				t.Errorf("got\n%s\nwant suffix\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestHelpEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.GaugeFunc("g", "Back\\slash and \"quotes\"\nacross lines.", func() float64 { return 1 })
+
+	want := `# HELP g Back\\slash and "quotes"\nacross lines.` + "\n"
+	if got := text(t, r); !strings.HasPrefix(got, want) {
+		t.Errorf("got\n%s\nwant prefix\n%s", got, want)
+	}
+}
+
+func TestGauges(t *testing.T) {
+	r := NewRegistry()
+	value := 3.0
+	r.GaugeFunc("plain", "Plain gauge.", func() float64 { return value })
+	r.GaugeVecFunc("by_state", "Gauge by state.", "state", func() map[string]float64 {
+		return map[string]float64{"waiting": 2, "playing": value, `odd"one`: 0}
+	})
+
+	value = 4
+	want := `# HELP plain Plain gauge.
+# TYPE plain gauge
+plain 4
+# HELP by_state Gauge by state.
+# TYPE by_state gauge
+by_state{state="odd\"one"} 0
+by_state{state="playing"} 4
+by_state{state="waiting"} 2
+`
+	if got := text(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{-2.5, "-2.5"},
+		{0.0005, "0.0005"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFloat(tt.v); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c_total", "Counter.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if body := rec.Body.String(); !strings.HasSuffix(body, "c_total 1\n") {
+		t.Errorf("body %q", body)
+	}
+}
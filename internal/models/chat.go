@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ChatMessage is one line of in-game chat
+type ChatMessage struct {
+	PlayerID   string    `json:"player_id"`
+	PlayerName string    `json:"player_name"`
+	Text       string    `json:"text"`
+	SentAt     time.Time `json:"sent_at"`
+}
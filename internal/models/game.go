@@ -1,6 +1,8 @@
 package models
 
 import (
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -8,39 +10,77 @@ import (
 type GameState string
 
 const (
-	StateWaiting  GameState = "waiting"   // Waiting for players
-	StatePlaying  GameState = "playing"   // Game in progress
-	StateScoring  GameState = "scoring"   // Between rounds, showing scores
-	StateFinished GameState = "finished"  // Game complete
+	StateWaiting  GameState = "waiting"  // Waiting for players
+	StatePlaying  GameState = "playing"  // Game in progress
+	StateScoring  GameState = "scoring"  // Between rounds, showing scores
+	StateFinished GameState = "finished" // Game complete
 )
 
 // Game represents a game room
 type Game struct {
-	ID          string               `json:"id"`
-	Players     map[string]*Player   `json:"players"`      // PlayerID -> Player
-	State       GameState            `json:"state"`
-	Round       int                  `json:"round"`        // 1, 2, or 3
-	Turn        int                  `json:"turn"`         // Current turn in round
-	Deck        []Card               `json:"-"`            // Remaining cards in deck
-	HostID      string               `json:"host_id"`
-	CreatedAt   time.Time            `json:"created_at"`
-	MaxPlayers  int                  `json:"max_players"`
-	MinPlayers  int                  `json:"min_players"`
+	ID           string             `json:"id"`
+	Players      map[string]*Player `json:"players"` // PlayerID -> Player
+	State        GameState          `json:"state"`
+	Round        int                `json:"round"` // 1, 2, or 3
+	Turn         int                `json:"turn"`  // Current turn in round
+	Deck         []Card             `json:"-"`     // Remaining cards in deck
+	HostID       string             `json:"host_id"`
+	CreatedAt    time.Time          `json:"created_at"`
+	LastActivity time.Time          `json:"last_activity"` // Last join, connection or message
+	StartedAt    time.Time          `json:"started_at"`
+	FinishedAt   time.Time          `json:"finished_at"`
+	MaxPlayers   int                `json:"max_players"`
+	MinPlayers   int                `json:"min_players"`
+	Chat         []ChatMessage      `json:"-"` // Recent chat, oldest first
+	Password     []byte             `json:"-"` // Salted hash; nil unless the room is private
+
+	mu sync.Mutex // Guards everything above except ID, CreatedAt and Password, which never change
 }
 
 // NewGame creates a new game room
 func NewGame(id string, hostID string) *Game {
+	now := time.Now()
 	return &Game{
-		ID:         id,
-		Players:    make(map[string]*Player),
-		State:      StateWaiting,
-		Round:      0,
-		Turn:       0,
-		Deck:       []Card{},
-		HostID:     hostID,
-		CreatedAt:  time.Now(),
-		MaxPlayers: 5,
-		MinPlayers: 2,
+		ID:           id,
+		Players:      make(map[string]*Player),
+		State:        StateWaiting,
+		Round:        0,
+		Turn:         0,
+		Deck:         []Card{},
+		HostID:       hostID,
+		CreatedAt:    now,
+		LastActivity: now,
+		MaxPlayers:   5,
+		MinPlayers:   2,
+	}
+}
+
+// Lock takes the game's lock. Callers hold it while reading or changing
+// the game, so games can be played in parallel.
+func (g *Game) Lock() {
+	g.mu.Lock()
+}
+
+// Unlock releases the game's lock
+func (g *Game) Unlock() {
+	g.mu.Unlock()
+}
+
+// Private reports whether joining needs a password or invite
+func (g *Game) Private() bool {
+	return len(g.Password) > 0
+}
+
+// IdleFor returns how long the game has gone without activity
+func (g *Game) IdleFor(now time.Time) time.Duration {
+	return now.Sub(g.LastActivity)
+}
+
+// AddChat records a chat message, keeping only the most recent keep messages
+func (g *Game) AddChat(msg ChatMessage, keep int) {
+	g.Chat = append(g.Chat, msg)
+	if len(g.Chat) > keep {
+		g.Chat = append([]ChatMessage(nil), g.Chat[len(g.Chat)-keep:]...)
 	}
 }
 
@@ -60,6 +100,66 @@ func (g *Game) AllPlayersSelected() bool {
 	return true
 }
 
+// Seats returns the players in seating order: join order, then ID. Hands
+// pass to the next seat and clients list players in this order, so it must
+// not change while the game runs.
+func (g *Game) Seats() []*Player {
+	players := make([]*Player, 0, len(g.Players))
+	for _, p := range g.Players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		return a.ID < b.ID
+	})
+	return players
+}
+
+// HumanCount returns the number of players not replaced by a bot
+func (g *Game) HumanCount() int {
+	count := 0
+	for _, player := range g.Players {
+		if !player.IsBot {
+			count++
+		}
+	}
+	return count
+}
+
+// NextHostID picks the player who should take over from the host: the
+// human connected the longest, or failing that the one who joined first.
+// It returns "" if there is no other human player.
+func (g *Game) NextHostID() string {
+	var next *Player
+	for id, player := range g.Players {
+		if id == g.HostID || player.IsBot {
+			continue
+		}
+		if next == nil || betterHost(player, next) {
+			next = player
+		}
+	}
+
+	if next == nil {
+		return ""
+	}
+	return next.ID
+}
+
+// betterHost reports whether a should be preferred over b as host
+func betterHost(a, b *Player) bool {
+	if a.IsConnected() != b.IsConnected() {
+		return a.IsConnected()
+	}
+	if a.IsConnected() {
+		return a.ConnectedAt.Before(b.ConnectedAt)
+	}
+	return a.JoinedAt.Before(b.JoinedAt)
+}
+
 // CardsPerHand returns number of cards dealt based on player count
 func (g *Game) CardsPerHand() int {
 	playerCount := len(g.Players)
@@ -1,16 +1,29 @@
 package models
 
+import (
+	"time"
+)
+
 // Player represents a player in the game
 type Player struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Hand        []Card   `json:"hand"`         // Current cards in hand
-	PlayedCards []Card   `json:"played_cards"` // Cards played this round
-	Score       int      `json:"score"`
-	RoundScores []int    `json:"round_scores"` // Score per round
-	IsReady     bool     `json:"is_ready"`
-	HasSelected bool     `json:"has_selected"` // Has selected card this turn
-	DosaActive  bool     `json:"dosa_active"`  // Has active Dosa multiplier
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Hand        []Card    `json:"hand"`         // Current cards in hand
+	PlayedCards []Card    `json:"played_cards"` // Cards played this round
+	Score       int       `json:"score"`
+	RoundScores []int     `json:"round_scores"` // Score per round
+	IsReady     bool      `json:"is_ready"`
+	HasSelected bool      `json:"has_selected"` // Has selected card this turn
+	DosaActive  bool      `json:"dosa_active"`  // Has active Dosa multiplier
+	IsBot       bool      `json:"is_bot"`       // Player left mid-game and a bot plays their hand
+	JoinedAt    time.Time `json:"joined_at"`
+	ConnectedAt time.Time `json:"connected_at"` // When the first open connection was made; zero while disconnected
+	Connections int       `json:"connections"`  // Open connections (tabs or devices)
+}
+
+// IsConnected reports whether the player has an open connection
+func (p *Player) IsConnected() bool {
+	return p.Connections > 0
 }
 
 // NewPlayer creates a new player
@@ -25,5 +38,7 @@ func NewPlayer(id, name string) *Player {
 		IsReady:     false,
 		HasSelected: false,
 		DosaActive:  false,
+		IsBot:       false,
+		JoinedAt:    time.Now(),
 	}
 }
@@ -0,0 +1,374 @@
+// Package protocol defines the messages exchanged over the game WebSocket.
+//
+// Every message is a JSON envelope {"type": ..., "data": ...}. The data
+// payload for each type is one of the structs below.
+package protocol
+
+//go:generate go run ../../cmd/protocol-schema -o ../../docs/protocol.schema.json
+
+import (
+	"encoding/json"
+
+	"github.com/aiplaybookin/tiffin-go/internal/jsonpatch"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// Version is the protocol version spoken by this server
+const Version = 2
+
+// Client → server message types
+const (
+	TypeHello        = "hello"         // Protocol version handshake
+	TypeStartGame    = "start_game"    // Host starts the game
+	TypeSelectCard   = "select_card"   // Player selects a card
+	TypeGetState     = "get_state"     // Request a state snapshot
+	TypeResync       = "resync"        // Request a snapshot after a missed patch
+	TypeLeaveGame    = "leave_game"    // Player leaves the game
+	TypeKickPlayer   = "kick_player"   // Host removes a player
+	TypeTransferHost = "transfer_host" // Host hands the host role to another player
+	TypeChatMessage  = "chat_message"  // Player says something (also relayed to the room)
+	TypeReaction     = "reaction"      // Player reacts with an emoji (also relayed to the room)
+	TypeCreateInvite = "create_invite" // Host asks for an invite link
+)
+
+// Server → client message types
+const (
+	TypeWelcome            = "welcome"              // Handshake accepted
+	TypeStateSnapshot      = "state_snapshot"       // Full game state
+	TypeStatePatch         = "state_patch"          // Changes since the previous state message
+	TypePlayerJoined       = "player_joined"        // New player joined lobby
+	TypeError              = "error"                // Error message
+	TypeServerShuttingDown = "server_shutting_down" // Server is about to stop
+	TypeExpired            = "expired"              // Game was removed for inactivity
+	TypePlayerLeft         = "player_left"          // Player left or was kicked
+	TypeHostChanged        = "host_changed"         // Another player is now the host
+	TypeChatHistory        = "chat_history"         // Recent chat, sent on connect
+	TypeServerNotice       = "server_notice"        // Announcement from the server operator
+	TypeGameClosed         = "game_closed"          // Game was removed by the server operator
+	TypeInvite             = "invite"               // Invite token requested by the host
+)
+
+// ErrorCode is a stable, machine-readable error identifier
+type ErrorCode string
+
+// Error codes sent in ErrorData
+const (
+	ErrCodeBadMessage         ErrorCode = "bad_message"          // Message or payload is not valid JSON for its type
+	ErrCodeUnknownType        ErrorCode = "unknown_type"         // Message type is not part of the protocol
+	ErrCodeUnsupportedVersion ErrorCode = "unsupported_version"  // Client speaks a protocol version we don't
+	ErrCodeGameNotFound       ErrorCode = "game_not_found"       // Game no longer exists
+	ErrCodeNotHost            ErrorCode = "not_host"             // Action is reserved for the host
+	ErrCodeInvalidAction      ErrorCode = "invalid_action"       // Action is not allowed right now
+	ErrCodeBadRequest         ErrorCode = "bad_request"          // HTTP request body or parameters are invalid
+	ErrCodeMethodNotAllowed   ErrorCode = "method_not_allowed"   // HTTP method is not supported
+	ErrCodePlayerNotFound     ErrorCode = "player_not_found"     // Player is not in the game
+	ErrCodeGameFull           ErrorCode = "game_full"            // Game has no free seats
+	ErrCodeGameStarted        ErrorCode = "game_started"         // Game has already started
+	ErrCodeNotEnoughPlayers   ErrorCode = "not_enough_players"   // Game cannot start with this many players
+	ErrCodeGameNotPlaying     ErrorCode = "game_not_playing"     // Game is not accepting card selections
+	ErrCodeNotYourTurn        ErrorCode = "not_your_turn"        // Player already selected a card this turn
+	ErrCodeInvalidCardIndex   ErrorCode = "invalid_card_index"   // Card index is outside the hand
+	ErrCodeServerFull         ErrorCode = "server_full"          // Server is not accepting new games
+	ErrCodeShuttingDown       ErrorCode = "shutting_down"        // Server is shutting down
+	ErrCodeTooManyConnections ErrorCode = "too_many_connections" // Player has too many open connections
+	ErrCodeRateLimited        ErrorCode = "rate_limited"         // Too many requests; slow down
+	ErrCodeOriginNotAllowed   ErrorCode = "origin_not_allowed"   // WebSocket origin is not on the allowlist
+	ErrCodeMessageTooLong     ErrorCode = "message_too_long"     // Chat message exceeds the length limit
+	ErrCodeChatRejected       ErrorCode = "chat_rejected"        // Chat filter refused the message
+	ErrCodeUnauthorized       ErrorCode = "unauthorized"         // Admin request has a missing or wrong token
+	ErrCodeGameFinished       ErrorCode = "game_finished"        // Game has already finished
+	ErrCodePasswordRequired   ErrorCode = "password_required"    // Private room needs a password or invite
+	ErrCodeWrongPassword      ErrorCode = "wrong_password"       // Room password does not match
+	ErrCodeInvalidInvite      ErrorCode = "invalid_invite"       // Invite token is malformed or for another game
+	ErrCodeInviteExpired      ErrorCode = "invite_expired"       // Invite token is past its expiry
+	ErrCodeInternal           ErrorCode = "internal_error"       // Unexpected server error
+)
+
+// ErrorCodes lists every error code
+var ErrorCodes = []ErrorCode{
+	ErrCodeBadMessage,
+	ErrCodeUnknownType,
+	ErrCodeUnsupportedVersion,
+	ErrCodeGameNotFound,
+	ErrCodeNotHost,
+	ErrCodeInvalidAction,
+	ErrCodeBadRequest,
+	ErrCodeMethodNotAllowed,
+	ErrCodePlayerNotFound,
+	ErrCodeGameFull,
+	ErrCodeGameStarted,
+	ErrCodeNotEnoughPlayers,
+	ErrCodeGameNotPlaying,
+	ErrCodeNotYourTurn,
+	ErrCodeInvalidCardIndex,
+	ErrCodeServerFull,
+	ErrCodeShuttingDown,
+	ErrCodeTooManyConnections,
+	ErrCodeRateLimited,
+	ErrCodeOriginNotAllowed,
+	ErrCodeMessageTooLong,
+	ErrCodeChatRejected,
+	ErrCodeUnauthorized,
+	ErrCodeGameFinished,
+	ErrCodePasswordRequired,
+	ErrCodeWrongPassword,
+	ErrCodeInvalidInvite,
+	ErrCodeInviteExpired,
+	ErrCodeInternal,
+}
+
+// Message is an incoming message with its payload left raw
+type Message struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Envelope is an outgoing message
+type Envelope struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// HelloData is sent by the client to announce its protocol version
+type HelloData struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// StartGameData is the (empty) payload of start_game
+type StartGameData struct{}
+
+// SelectCardData represents data for selecting a card
+type SelectCardData struct {
+	CardIndex int `json:"card_index"`
+}
+
+// GetStateData is the (empty) payload of get_state
+type GetStateData struct{}
+
+// LeaveGameData is the (empty) payload of leave_game
+type LeaveGameData struct{}
+
+// KickPlayerData names the player the host wants to remove
+type KickPlayerData struct {
+	PlayerID string `json:"player_id"`
+}
+
+// TransferHostData names the player who should become host
+type TransferHostData struct {
+	PlayerID string `json:"player_id"`
+}
+
+// ResyncData asks for a fresh snapshot. LastSeq is the last sequence number
+// the client applied, for logging only.
+type ResyncData struct {
+	LastSeq uint64 `json:"last_seq"`
+}
+
+// ChatMessageData is the text a player wants to say
+type ChatMessageData struct {
+	Text string `json:"text"`
+}
+
+// ReactionData is the emoji a player reacts with
+type ReactionData struct {
+	Emoji string `json:"emoji"`
+}
+
+// WelcomeData acknowledges a hello
+type WelcomeData struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	GameID          string `json:"game_id"`
+	PlayerID        string `json:"player_id"`
+}
+
+// GameState is a game as seen by one player
+type GameState struct {
+	ID         string           `json:"id"`
+	State      models.GameState `json:"state"`
+	Round      int              `json:"round"`
+	Turn       int              `json:"turn"`
+	HostID     string           `json:"host_id"`
+	MinPlayers int              `json:"min_players"`
+	MaxPlayers int              `json:"max_players"`
+	Private    bool             `json:"private"` // Joining needs a password or invite
+	Players    []PlayerState    `json:"players"`
+}
+
+// StateSnapshotData carries a player's full view of the game. Seq numbers
+// every state message on a connection, starting at 1.
+type StateSnapshotData struct {
+	Seq   uint64    `json:"seq"`
+	State GameState `json:"state"`
+}
+
+// StatePatchData carries JSON Patch operations to apply to the state from
+// message Seq-1. A client that has not applied exactly that state should
+// send resync.
+type StatePatchData struct {
+	Seq uint64                `json:"seq"`
+	Ops []jsonpatch.Operation `json:"ops"`
+}
+
+// PlayerState is one player's entry in a GameState. Hand is only sent to
+// the player it belongs to.
+type PlayerState struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Score       int           `json:"score"`
+	RoundScores []int         `json:"round_scores"`
+	HasSelected bool          `json:"has_selected"`
+	PlayedCards []models.Card `json:"played_cards"`
+	HandSize    int           `json:"hand_size"`
+	Hand        []models.Card `json:"hand,omitempty"`
+	IsMe        bool          `json:"is_me"`
+	IsBot       bool          `json:"is_bot"`
+	Connections int           `json:"connections"` // Open connections (tabs or devices)
+}
+
+// PlayerJoinedData announces a new player in the lobby
+type PlayerJoinedData struct {
+	PlayerID   string `json:"player_id"`
+	PlayerName string `json:"player_name"`
+}
+
+// Reasons a player left a game
+const (
+	LeaveReasonLeft   = "left"
+	LeaveReasonKicked = "kicked"
+)
+
+// PlayerLeftData announces that a player is no longer in the game
+type PlayerLeftData struct {
+	PlayerID      string `json:"player_id"`
+	PlayerName    string `json:"player_name"`
+	Reason        string `json:"reason"`                // left or kicked
+	ReplacedByBot bool   `json:"replaced_by_bot"`       // A bot now plays their hand
+	NewHostID     string `json:"new_host_id,omitempty"` // Set if the host changed
+}
+
+// Reasons the host changed
+const (
+	HostReasonTransferred  = "transferred"
+	HostReasonLeft         = "left"
+	HostReasonDisconnected = "disconnected"
+)
+
+// HostChangedData announces a new host
+type HostChangedData struct {
+	HostID   string `json:"host_id"`
+	HostName string `json:"host_name"`
+	Reason   string `json:"reason"` // transferred, left or disconnected
+}
+
+// ChatBroadcastData is a chat message relayed to the room
+type ChatBroadcastData struct {
+	PlayerID   string `json:"player_id"`
+	PlayerName string `json:"player_name"`
+	Text       string `json:"text"`
+	SentAt     int64  `json:"sent_at"` // Unix milliseconds
+}
+
+// ReactionBroadcastData is a reaction relayed to the room
+type ReactionBroadcastData struct {
+	PlayerID   string `json:"player_id"`
+	PlayerName string `json:"player_name"`
+	Emoji      string `json:"emoji"`
+}
+
+// ChatHistoryData replays recent chat, oldest first
+type ChatHistoryData struct {
+	Messages []ChatBroadcastData `json:"messages"`
+}
+
+// ErrorData reports a failed request
+type ErrorData struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ServerShuttingDownData warns clients that the server is stopping
+type ServerShuttingDownData struct {
+	Message string `json:"message"`
+}
+
+// ExpiredData tells clients their game was removed for inactivity
+type ExpiredData struct {
+	GameID  string `json:"game_id"`
+	Message string `json:"message"`
+}
+
+// ServerNoticeData is an announcement shown to players
+type ServerNoticeData struct {
+	Message string `json:"message"`
+}
+
+// GameClosedData tells clients their game was removed by the operator
+type GameClosedData struct {
+	GameID  string `json:"game_id"`
+	Message string `json:"message"`
+}
+
+// CreateInviteData is the (empty) payload of create_invite
+type CreateInviteData struct{}
+
+// InviteData carries a signed invite token for the host to share. Passing
+// it as "invite" to /api/join admits a player without the room password.
+type InviteData struct {
+	GameID    string `json:"game_id"`
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"` // Unix milliseconds
+}
+
+// ClientMessages maps each client → server type to its payload
+var ClientMessages = map[string]interface{}{
+	TypeHello:        HelloData{},
+	TypeStartGame:    StartGameData{},
+	TypeSelectCard:   SelectCardData{},
+	TypeGetState:     GetStateData{},
+	TypeResync:       ResyncData{},
+	TypeLeaveGame:    LeaveGameData{},
+	TypeKickPlayer:   KickPlayerData{},
+	TypeTransferHost: TransferHostData{},
+	TypeChatMessage:  ChatMessageData{},
+	TypeReaction:     ReactionData{},
+	TypeCreateInvite: CreateInviteData{},
+}
+
+// ServerMessages maps each server → client type to its payload
+var ServerMessages = map[string]interface{}{
+	TypeWelcome:            WelcomeData{},
+	TypeStateSnapshot:      StateSnapshotData{},
+	TypeStatePatch:         StatePatchData{},
+	TypePlayerJoined:       PlayerJoinedData{},
+	TypeError:              ErrorData{},
+	TypeServerShuttingDown: ServerShuttingDownData{},
+	TypeExpired:            ExpiredData{},
+	TypePlayerLeft:         PlayerLeftData{},
+	TypeHostChanged:        HostChangedData{},
+	TypeChatMessage:        ChatBroadcastData{},
+	TypeReaction:           ReactionBroadcastData{},
+	TypeChatHistory:        ChatHistoryData{},
+	TypeServerNotice:       ServerNoticeData{},
+	TypeGameClosed:         GameClosedData{},
+	TypeInvite:             InviteData{},
+}
+
+// Me returns the viewing player's entry
+func (s *GameState) Me() *PlayerState {
+	for i := range s.Players {
+		if s.Players[i].IsMe {
+			return &s.Players[i]
+		}
+	}
+	return nil
+}
+
+// Player returns the entry for a player ID
+func (s *GameState) Player(playerID string) *PlayerState {
+	for i := range s.Players {
+		if s.Players[i].ID == playerID {
+			return &s.Players[i]
+		}
+	}
+	return nil
+}
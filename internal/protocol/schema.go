@@ -0,0 +1,163 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// enums lists the allowed values of string types used in payloads
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(models.CardType("")): {
+		string(models.Samosa),
+		string(models.Biryani),
+		string(models.Chai),
+		string(models.GurabJamun),
+		string(models.PaneerTikka),
+		string(models.Dosa),
+		string(models.Raita),
+	},
+	reflect.TypeOf(models.GameState("")): {
+		string(models.StateWaiting),
+		string(models.StatePlaying),
+		string(models.StateScoring),
+		string(models.StateFinished),
+	},
+}
+
+// Error codes come from ErrorCodes so the schema stays in sync with them
+func init() {
+	codes := make([]string, 0, len(ErrorCodes))
+	for _, code := range ErrorCodes {
+		codes = append(codes, string(code))
+	}
+	enums[reflect.TypeOf(ErrorCode(""))] = codes
+}
+
+// Schema builds a JSON Schema document describing every message
+func Schema() map[string]interface{} {
+	defs := make(map[string]interface{})
+
+	return map[string]interface{}{
+		"$schema":          "https://json-schema.org/draft/2020-12/schema",
+		"title":            "Tiffin Go WebSocket protocol",
+		"protocol_version": Version,
+		"$defs":            defs,
+		"properties": map[string]interface{}{
+			"client_message": map[string]interface{}{"oneOf": messageSchemas(ClientMessages, defs)},
+			"server_message": map[string]interface{}{"oneOf": messageSchemas(ServerMessages, defs)},
+		},
+	}
+}
+
+// SchemaJSON returns the schema as indented JSON
+func SchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// messageSchemas describes one envelope per message type
+func messageSchemas(messages map[string]interface{}, defs map[string]interface{}) []interface{} {
+	types := make([]string, 0, len(messages))
+	for msgType := range messages {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+
+	schemas := make([]interface{}, 0, len(types))
+	for _, msgType := range types {
+		schemas = append(schemas, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type": map[string]interface{}{"const": msgType},
+				"data": typeSchema(reflect.TypeOf(messages[msgType]), defs),
+			},
+			"required": []string{"type"},
+		})
+	}
+	return schemas
+}
+
+// typeSchema describes a Go type, adding named structs to defs
+func typeSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	if values, ok := enums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, seen := defs[t.Name()]; !seen {
+			defs[t.Name()] = nil // Reserve the name before recursing
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+
+	return map[string]interface{}{}
+}
+
+// structSchema describes a struct from its json tags
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		properties[name] = typeSchema(field.Type, defs)
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
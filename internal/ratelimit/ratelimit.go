@@ -0,0 +1,120 @@
+// Package ratelimit provides an in-process token bucket limiter keyed by
+// client, usually an IP address.
+//
+// Each key gets a bucket holding up to burst tokens that refills at rate
+// tokens per second. Every allowed request takes one token. A nil *Limiter
+// allows everything, so a disabled limit needs no special casing.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter rate limits requests per key
+type Limiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket size
+
+	buckets map[string]*bucket
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// bucket is one key's token count as of last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter allowing rate requests per second with bursts of up
+// to burst. It returns nil (no limit) if rate is not positive.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// PerMinute creates a limiter allowing n requests per minute, all of which
+// may arrive at once. It returns nil (no limit) if n is not positive.
+func PerMinute(n int) *Limiter {
+	return New(float64(n)/60, n)
+}
+
+// Allow takes a token for key if one is available. Otherwise it reports how
+// long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.refill(key, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / l.rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Prune forgets keys whose buckets have refilled completely. Those keys would
+// get a full bucket again anyway, so this only frees memory.
+func (l *Limiter) Prune() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key := range l.buckets {
+		if l.refill(key, now).tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of keys being tracked
+func (l *Limiter) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// refill returns key's bucket topped up to now; caller holds l.mu
+func (l *Limiter) refill(key string, now time.Time) *bucket {
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+	return b
+}
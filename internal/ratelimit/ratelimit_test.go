@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock tests move by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestLimiter creates a limiter on a fake clock
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(rate, burst)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      float64
+		burst     int
+		wantNil   bool
+		wantBurst float64
+	}{
+		{"limited", 2, 5, false, 5},
+		{"zero rate disables", 0, 5, true, 0},
+		{"negative rate disables", -1, 5, true, 0},
+		{"burst raised to one", 2, 0, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.rate, tt.burst)
+			if (l == nil) != tt.wantNil {
+				t.Fatalf("New(%v, %d) = %v, want nil %v", tt.rate, tt.burst, l, tt.wantNil)
+			}
+			if l != nil && l.burst != tt.wantBurst {
+				t.Errorf("burst %v, want %v", l.burst, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestPerMinute(t *testing.T) {
+	if l := PerMinute(0); l != nil {
+		t.Errorf("PerMinute(0) = %v, want nil", l)
+	}
+
+	l := PerMinute(30)
+	if l.rate != 0.5 || l.burst != 30 {
+		t.Errorf("PerMinute(30) rate %v burst %v, want 0.5 and 30", l.rate, l.burst)
+	}
+}
+
+func TestNilLimiterAllowsEverything(t *testing.T) {
+	var l *Limiter
+	for i := 0; i < 100; i++ {
+		if ok, wait := l.Allow("key"); !ok || wait != 0 {
+			t.Fatalf("Allow = %v, %v, want true, 0", ok, wait)
+		}
+	}
+	l.Prune()
+	if l.Len() != 0 {
+		t.Errorf("Len = %d, want 0", l.Len())
+	}
+}
+
+func TestBurst(t *testing.T) {
+	l, _ := newTestLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d rejected within burst", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request past burst allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("retry after %v, want 1s", wait)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		allowed int // Requests allowed after waiting
+		wait    time.Duration
+	}{
+		{"no time", 0, 0, 500 * time.Millisecond},
+		{"part of a token", 200 * time.Millisecond, 0, 300 * time.Millisecond},
+		{"one token", 500 * time.Millisecond, 1, 500 * time.Millisecond},
+		{"two tokens", time.Second, 2, 500 * time.Millisecond},
+		{"capped at burst", time.Hour, 4, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(2, 4)
+			for i := 0; i < 4; i++ {
+				l.Allow("a")
+			}
+
+			clock.Advance(tt.elapsed)
+
+			for i := 0; i < tt.allowed; i++ {
+				if ok, _ := l.Allow("a"); !ok {
+					t.Fatalf("request %d rejected after %v", i+1, tt.elapsed)
+				}
+			}
+			ok, wait := l.Allow("a")
+			if ok {
+				t.Fatalf("request %d allowed after %v", tt.allowed+1, tt.elapsed)
+			}
+			if wait != tt.wait {
+				t.Errorf("retry after %v, want %v", wait, tt.wait)
+			}
+		})
+	}
+}
+
+func TestKeysAreIsolated(t *testing.T) {
+	l, _ := newTestLimiter(1, 2)
+
+	l.Allow("a")
+	l.Allow("a")
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("a allowed past its burst")
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("b"); !ok {
+			t.Fatalf("b request %d rejected because a is limited", i+1)
+		}
+	}
+	if l.Len() != 2 {
+		t.Errorf("Len = %d, want 2", l.Len())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l, clock := newTestLimiter(1, 2)
+
+	l.Allow("full")
+	l.Allow("empty")
+	l.Allow("empty")
+	clock.Advance(time.Second)
+
+	// "full" is back to two tokens; "empty" only has one
+	l.Prune()
+	if l.Len() != 1 {
+		t.Fatalf("Len = %d after prune, want 1", l.Len())
+	}
+	if _, kept := l.buckets["empty"]; !kept {
+		t.Error("pruned a key that was still limited")
+	}
+
+	clock.Advance(time.Second)
+	l.Prune()
+	if l.Len() != 0 {
+		t.Errorf("Len = %d after refill, want 0", l.Len())
+	}
+}
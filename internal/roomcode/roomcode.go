@@ -0,0 +1,73 @@
+// Package roomcode generates game codes people can read aloud and type.
+//
+// A code is six consonant-vowel syllables in three groups, such as
+// KAZU-MEBU-DATE. The letters leave out I, L, O and Q, which are easily
+// confused with digits or each other, and W, X and Y, which are awkward to
+// say. That gives 16 consonants and 3 vowels, so 48^6 (about 1.2e10) codes,
+// compared with the 16.7 million six-digit hex codes used before.
+package roomcode
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const (
+	consonants = "BCDFGHJKMNPRSTVZ"
+	vowels     = "AEU"
+
+	syllables      = 6
+	groupSyllables = 2 // Syllables between hyphens
+)
+
+// Length is the length of a code, including its hyphens
+const Length = syllables*2 + syllables/groupSyllables - 1
+
+// New returns a random code. It does not check for collisions; the caller
+// owns the set of codes in use.
+func New() string {
+	var b strings.Builder
+	b.Grow(Length)
+	for i := 0; i < syllables; i++ {
+		if i > 0 && i%groupSyllables == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(pick(consonants))
+		b.WriteByte(pick(vowels))
+	}
+	return b.String()
+}
+
+// Normalize turns a code as a person typed it into canonical form: upper
+// case, with the hyphens restored and spaces or other separators removed.
+// Anything that can't be a code is returned upper-cased and trimmed, so it
+// simply won't match a game.
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	letters := strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, code)
+	if len(letters) != syllables*2 {
+		return code
+	}
+
+	var b strings.Builder
+	b.Grow(Length)
+	for i := 0; i < len(letters); i += groupSyllables * 2 {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(letters[i : i+groupSyllables*2])
+	}
+	return b.String()
+}
+
+// pick returns a uniformly random byte of set
+func pick(set string) byte {
+	n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	return set[n.Int64()]
+}
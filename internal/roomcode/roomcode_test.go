@@ -0,0 +1,70 @@
+package roomcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code := New()
+		if len(code) != Length {
+			t.Fatalf("%q has length %d, want %d", code, len(code), Length)
+		}
+
+		groups := strings.Split(code, "-")
+		if len(groups) != syllables/groupSyllables {
+			t.Fatalf("%q has %d groups, want %d", code, len(groups), syllables/groupSyllables)
+		}
+		for _, group := range groups {
+			for j := 0; j < len(group); j += 2 {
+				if !strings.ContainsRune(consonants, rune(group[j])) || !strings.ContainsRune(vowels, rune(group[j+1])) {
+					t.Fatalf("%q: %q is not a consonant then a vowel", code, group[j:j+2])
+				}
+			}
+		}
+		if strings.ContainsAny(code, "ILOQWXY") {
+			t.Fatalf("%q uses a left-out letter", code)
+		}
+
+		if Normalize(code) != code {
+			t.Errorf("Normalize(%q) = %q, want it unchanged", code, Normalize(code))
+		}
+		seen[code] = true
+	}
+
+	// 1000 draws from 48^6 codes should all differ
+	if len(seen) < 999 {
+		t.Errorf("%d distinct codes in 1000, want them random", len(seen))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"canonical", "KAZU-MEBU-DATE", "KAZU-MEBU-DATE"},
+		{"lower case", "kazu-mebu-date", "KAZU-MEBU-DATE"},
+		{"mixed case", "KaZu-MeBu-DaTe", "KAZU-MEBU-DATE"},
+		{"no hyphens", "kazumebudate", "KAZU-MEBU-DATE"},
+		{"spaces", "kazu mebu date", "KAZU-MEBU-DATE"},
+		{"padding", "  KAZU-MEBU-DATE \n", "KAZU-MEBU-DATE"},
+		{"other separators", "kazu.mebu_date", "KAZU-MEBU-DATE"},
+		{"misplaced hyphens", "KA-ZUME-BUDA-TE", "KAZU-MEBU-DATE"},
+		{"too short", " kazu-mebu ", "KAZU-MEBU"},
+		{"too long", "kazu-mebu-date-ba", "KAZU-MEBU-DATE-BA"},
+		{"old hex code", "a1b2c3", "A1B2C3"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// AdminGame summarises a game for the admin game list
+type AdminGame struct {
+	ID           string           `json:"id"`
+	State        models.GameState `json:"state"`
+	Round        int              `json:"round"`
+	Turn         int              `json:"turn"`
+	HostID       string           `json:"host_id"`
+	Private      bool             `json:"private"`
+	Players      []AdminPlayer    `json:"players"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastActivity time.Time        `json:"last_activity"`
+}
+
+// AdminPlayer summarises a player for the admin game list
+type AdminPlayer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Score       int    `json:"score"`
+	IsBot       bool   `json:"is_bot"`
+	Connections int    `json:"connections"`
+}
+
+// AdminGamesResponse is the admin game list with server totals
+type AdminGamesResponse struct {
+	Games   []AdminGame `json:"games"`
+	Players int         `json:"players"`
+	Clients int         `json:"clients"` // Open WebSocket connections
+}
+
+// AdminGameDetail is a game's full state, including every hand, the deck
+// and the chat history
+type AdminGameDetail struct {
+	*models.Game
+	Deck []models.Card        `json:"deck"`
+	Chat []models.ChatMessage `json:"chat"`
+}
+
+// AdminConnectionsResponse lists every open WebSocket with its health
+type AdminConnectionsResponse struct {
+	Connections []ClientStats `json:"connections"`
+}
+
+// AdminNoticeRequest asks for a notice to be shown to players
+type AdminNoticeRequest struct {
+	Message string `json:"message"`
+	GameID  string `json:"game_id,omitempty"` // Empty sends to every game
+}
+
+// registerAdminRoutes adds the admin API when an admin token is configured
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+	if s.adminToken == "" {
+		return
+	}
+
+	mux.Handle("/admin", http.RedirectHandler("/admin.html", http.StatusFound))
+	mux.HandleFunc("/admin/games", s.requireAdmin(s.HandleAdminGames))
+	mux.HandleFunc("/admin/games/{id}", s.requireAdmin(s.HandleAdminGame))
+	mux.HandleFunc("/admin/games/{id}/end", s.requireAdmin(s.HandleAdminEndGame))
+	mux.HandleFunc("/admin/notice", s.requireAdmin(s.HandleAdminNotice))
+	mux.HandleFunc("/admin/connections", s.requireAdmin(s.HandleAdminConnections))
+}
+
+// requireAdmin rejects requests without the admin bearer token
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			s.log.Warn("admin request rejected", "path", r.URL.Path, "ip", clientIP(r))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tiffin-go admin"`)
+			writeError(w, protocol.ErrCodeUnauthorized, "missing or invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HandleAdminGames lists every game
+func (s *Server) HandleAdminGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	games := s.gameManager.Games()
+	resp := AdminGamesResponse{Games: make([]AdminGame, 0, len(games))}
+	for _, g := range games {
+		// Lock each game so it isn't read halfway through an action
+		g.Lock()
+		resp.Games = append(resp.Games, adminGame(g))
+		g.Unlock()
+	}
+	_, resp.Players = s.gameManager.Counts()
+	resp.Clients = s.hub.ClientCount()
+
+	writeJSON(w, resp)
+}
+
+// HandleAdminGame returns a game's full state on GET and removes it on DELETE
+func (s *Server) HandleAdminGame(w http.ResponseWriter, r *http.Request) {
+	gameID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		unlock := s.gameManager.LockGame(gameID)
+		g, err := s.gameManager.GetGame(gameID)
+		var body []byte
+		if err == nil {
+			body, err = json.Marshal(AdminGameDetail{Game: g, Deck: g.Deck, Chat: g.Chat})
+		}
+		unlock()
+
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+
+	case http.MethodDelete:
+		unlock := s.gameManager.LockGame(gameID)
+		err := s.gameManager.RemoveGame(gameID, "admin")
+		unlock()
+
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		s.log.Info("admin removed game", "game_id", gameID, "ip", clientIP(r))
+
+		s.hub.BroadcastToGame(gameID, protocol.TypeGameClosed, protocol.GameClosedData{
+			GameID:  gameID,
+			Message: "game closed by the server operator",
+		})
+		s.hub.CloseGame(gameID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+// HandleAdminEndGame finishes a game early and shows players the final scores
+func (s *Server) HandleAdminEndGame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	gameID := r.PathValue("id")
+	if err := s.wsHandler.EndGame(gameID); err != nil {
+		writeErr(w, err)
+		return
+	}
+	s.log.Info("admin ended game", "game_id", gameID, "ip", clientIP(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminNotice shows a notice to the players in one game or in all of them
+func (s *Server) HandleAdminNotice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req AdminNoticeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, protocol.ErrCodeBadRequest, "invalid request body")
+		return
+	}
+
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		writeError(w, protocol.ErrCodeBadRequest, "message is required")
+		return
+	}
+
+	notice := protocol.ServerNoticeData{Message: req.Message}
+	if req.GameID != "" {
+		if _, err := s.gameManager.GetGame(req.GameID); err != nil {
+			writeErr(w, err)
+			return
+		}
+		s.hub.BroadcastToGame(req.GameID, protocol.TypeServerNotice, notice)
+	} else {
+		s.hub.BroadcastAll(protocol.TypeServerNotice, notice)
+	}
+	s.log.Info("admin sent notice", "game_id", req.GameID, "ip", clientIP(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminConnections lists open connections with their traffic, round
+// trip time and send queue, grouped by game. A game_id query parameter
+// limits the list to one game.
+func (s *Server) HandleAdminConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	gameID := r.URL.Query().Get("game_id")
+	resp := AdminConnectionsResponse{Connections: []ClientStats{}}
+	for _, stats := range s.ConnectionStats() {
+		if gameID == "" || stats.GameID == gameID {
+			resp.Connections = append(resp.Connections, stats)
+		}
+	}
+	sort.Slice(resp.Connections, func(i, j int) bool {
+		a, b := resp.Connections[i], resp.Connections[j]
+		if a.GameID != b.GameID {
+			return a.GameID < b.GameID
+		}
+		return a.ConnectedAt.Before(b.ConnectedAt)
+	})
+
+	writeJSON(w, resp)
+}
+
+// adminGame summarises a game; caller holds the game's lock
+func adminGame(g *models.Game) AdminGame {
+	summary := AdminGame{
+		ID:           g.ID,
+		State:        g.State,
+		Round:        g.Round,
+		Turn:         g.Turn,
+		HostID:       g.HostID,
+		Private:      g.Private(),
+		Players:      make([]AdminPlayer, 0, len(g.Players)),
+		CreatedAt:    g.CreatedAt,
+		LastActivity: g.LastActivity,
+	}
+	for _, p := range g.Seats() {
+		summary.Players = append(summary.Players, AdminPlayer{
+			ID:          p.ID,
+			Name:        p.Name,
+			Score:       p.Score,
+			IsBot:       p.IsBot,
+			Connections: p.Connections,
+		})
+	}
+	return summary
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,218 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+const adminToken = "test-admin-token-0123456789"
+
+// adminRequest sends an admin API request with the given token
+func adminRequest(t *testing.T, h *servertest.Harness, method, path, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, h.HTTP.URL+path, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.HTTP.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// newAdminHarness starts a server with the admin API enabled
+func newAdminHarness(t *testing.T) *servertest.Harness {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	cfg.AdminToken = adminToken
+	return servertest.NewWithConfig(t, cfg)
+}
+
+func TestAdminConnections(t *testing.T) {
+	h := newAdminHarness(t)
+
+	first := h.NewGame("Asha", "Ravi")
+	second := h.NewGame("Meera")
+
+	tests := []struct {
+		name    string
+		path    string
+		clients []*servertest.Client
+	}{
+		{"all", "/admin/connections", append(append([]*servertest.Client{}, first...), second...)},
+		{"one game", "/admin/connections?game_id=" + first[0].GameID, first},
+		{"unknown game", "/admin/connections?game_id=BABA-BABA-BABA", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := adminRequest(t, h, http.MethodGet, tt.path, adminToken)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+
+			var got server.AdminConnectionsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(got.Connections) != len(tt.clients) {
+				t.Fatalf("%d connections, want %d", len(got.Connections), len(tt.clients))
+			}
+
+			byPlayer := make(map[string]server.ClientStats)
+			for _, c := range got.Connections {
+				byPlayer[c.PlayerID] = c
+			}
+			for _, c := range tt.clients {
+				stats, ok := byPlayer[c.PlayerID]
+				switch {
+				case !ok:
+					t.Errorf("%s missing", c.PlayerID)
+				case stats.GameID != c.GameID:
+					t.Errorf("%s in game %s, want %s", c.PlayerID, stats.GameID, c.GameID)
+				case stats.MessagesIn < 2 || stats.MessagesOut < 1:
+					// Every client has sent hello and get_state and read the
+					// welcome; the last write may not be counted yet
+					t.Errorf("%s has %d messages in and %d out", c.PlayerID, stats.MessagesIn, stats.MessagesOut)
+				case stats.RemoteAddr == "":
+					t.Errorf("%s has no remote address", c.PlayerID)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminConnectionsNeedsToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.AdminToken = adminToken
+	h := servertest.NewWithConfig(t, cfg)
+
+	for _, token := range []string{"", "wrong-token-0123456789"} {
+		resp := adminRequest(t, h, http.MethodGet, "/admin/connections", token)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want %d", token, resp.StatusCode, http.StatusUnauthorized)
+		}
+
+		var got protocol.ErrorData
+		json.NewDecoder(resp.Body).Decode(&got)
+		if got.Code != protocol.ErrCodeUnauthorized {
+			t.Errorf("token %q: error %s, want %s", token, got.Code, protocol.ErrCodeUnauthorized)
+		}
+	}
+}
+
+func TestAdminGames(t *testing.T) {
+	h := newAdminHarness(t)
+	playing := h.NewGame("Asha", "Ravi")
+	playing[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	playing[0].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+	waiting := h.NewGame("Meera")[0]
+
+	resp := adminRequest(t, h, http.MethodGet, "/admin/games", adminToken)
+	var list server.AdminGamesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode games: %v", err)
+	}
+	if len(list.Games) != 2 || list.Players != 3 || list.Clients != 3 {
+		t.Fatalf("%d games, %d players, %d clients, want 2, 3 and 3", len(list.Games), list.Players, list.Clients)
+	}
+	wantStates := map[string]models.GameState{playing[0].GameID: models.StatePlaying, waiting.GameID: models.StateWaiting}
+	for _, g := range list.Games {
+		if g.State != wantStates[g.ID] {
+			t.Errorf("game %s is %s, want %s", g.ID, g.State, wantStates[g.ID])
+		}
+		if g.ID != playing[0].GameID {
+			continue
+		}
+		if g.HostID != playing[0].PlayerID || len(g.Players) != 2 || g.Players[0].Name != "Asha" || g.Players[1].Name != "Ravi" {
+			t.Errorf("game %+v, want Asha hosting Asha and Ravi", g)
+		}
+		for _, p := range g.Players {
+			if p.Connections != 1 || p.IsBot {
+				t.Errorf("%s has %d connections and bot %v, want 1 and false", p.Name, p.Connections, p.IsBot)
+			}
+		}
+	}
+
+	// The detail includes what players can't see, such as the deck
+	resp = adminRequest(t, h, http.MethodGet, "/admin/games/"+playing[0].GameID, adminToken)
+	var detail map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+		t.Fatalf("decode game: %v", err)
+	}
+	var deck []models.Card
+	json.Unmarshal(detail["deck"], &deck)
+	if resp.StatusCode != http.StatusOK || len(deck) == 0 {
+		t.Errorf("game detail: status %d with %d cards in the deck, want 200 and a deck", resp.StatusCode, len(deck))
+	}
+
+	resp = adminRequest(t, h, http.MethodGet, "/admin/games/BABA-BABA-BABA", adminToken)
+	expectHTTPError(t, resp, http.StatusNotFound, protocol.ErrCodeGameNotFound)
+}
+
+func TestAdminEndGame(t *testing.T) {
+	h := newAdminHarness(t)
+	clients := h.NewGame("Asha", "Ravi")
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	clients[0].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+	path := "/admin/games/" + clients[0].GameID + "/end"
+
+	resp := adminRequest(t, h, http.MethodGet, path, adminToken)
+	expectHTTPError(t, resp, http.StatusMethodNotAllowed, protocol.ErrCodeMethodNotAllowed)
+
+	if resp := adminRequest(t, h, http.MethodPost, path, adminToken); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("end game: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, c := range clients {
+		c.Expect(protocol.TypeServerNotice)
+		if state := c.Refresh(); state.State != models.StateFinished {
+			t.Errorf("%s sees the game %s, want finished", c.PlayerID, state.State)
+		}
+	}
+
+	resp = adminRequest(t, h, http.MethodPost, path, adminToken)
+	expectHTTPError(t, resp, http.StatusConflict, protocol.ErrCodeGameFinished)
+	resp = adminRequest(t, h, http.MethodPost, "/admin/games/BABA-BABA-BABA/end", adminToken)
+	expectHTTPError(t, resp, http.StatusNotFound, protocol.ErrCodeGameNotFound)
+}
+
+func TestAdminDeleteGame(t *testing.T) {
+	h := newAdminHarness(t)
+	clients := h.NewGame("Asha", "Ravi")
+	path := "/admin/games/" + clients[0].GameID
+
+	if resp := adminRequest(t, h, http.MethodDelete, path, adminToken); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete game: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, c := range clients {
+		var closed protocol.GameClosedData
+		if err := json.Unmarshal(c.Expect(protocol.TypeGameClosed).Data, &closed); err != nil {
+			t.Fatalf("decode game_closed: %v", err)
+		}
+		if closed.GameID != c.GameID {
+			t.Errorf("game_closed names %s, want %s", closed.GameID, c.GameID)
+		}
+		expectClosed(t, c)
+	}
+
+	resp := adminRequest(t, h, http.MethodGet, path, adminToken)
+	expectHTTPError(t, resp, http.StatusNotFound, protocol.ErrCodeGameNotFound)
+	resp = adminRequest(t, h, http.MethodDelete, path, adminToken)
+	expectHTTPError(t, resp, http.StatusNotFound, protocol.ErrCodeGameNotFound)
+}
@@ -0,0 +1,124 @@
+package server_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/chat"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+// chatConfig keeps short messages and a short history, and masks "rasam"
+func chatConfig() *config.Config {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate, cfg.ChatRate = 0, 0, 0, 0
+	cfg.ChatMaxLength, cfg.ChatHistory = 10, 2
+	cfg.ChatBlocklist = []string{"rasam"}
+	return cfg
+}
+
+// expectChat waits for a chat message and returns it
+func expectChat(t *testing.T, c *servertest.Client) protocol.ChatBroadcastData {
+	t.Helper()
+
+	var msg protocol.ChatBroadcastData
+	if err := json.Unmarshal(c.Expect(protocol.TypeChatMessage).Data, &msg); err != nil {
+		t.Fatalf("decode chat_message: %v", err)
+	}
+	return msg
+}
+
+// expectHistory waits for the chat history sent on connect
+func expectHistory(t *testing.T, c *servertest.Client) []protocol.ChatBroadcastData {
+	t.Helper()
+
+	var history protocol.ChatHistoryData
+	if err := json.Unmarshal(c.Expect(protocol.TypeChatHistory).Data, &history); err != nil {
+		t.Fatalf("decode chat_history: %v", err)
+	}
+	return history.Messages
+}
+
+func TestChatBroadcast(t *testing.T) {
+	h := servertest.NewWithConfig(t, chatConfig())
+	clients := h.NewGame("Asha", "Ravi")
+
+	// Control characters and padding are stripped, blocked words masked
+	clients[0].Send(protocol.TypeChatMessage, protocol.ChatMessageData{Text: "  hi\x07 Rasam\n"})
+	for _, c := range clients {
+		msg := expectChat(t, c)
+		if msg.PlayerID != clients[0].PlayerID || msg.PlayerName != "Asha" || msg.Text != "hi *****" || msg.SentAt == 0 {
+			t.Errorf("%s got %+v, want Asha's masked message", c.PlayerID, msg)
+		}
+	}
+
+	// The limit counts characters, not bytes
+	clients[1].Send(protocol.TypeChatMessage, protocol.ChatMessageData{Text: strings.Repeat("é", 10)})
+	if msg := expectChat(t, clients[0]); msg.Text != strings.Repeat("é", 10) {
+		t.Errorf("got %q, want ten é", msg.Text)
+	}
+}
+
+func TestChatRejected(t *testing.T) {
+	h := servertest.NewWithConfig(t, chatConfig())
+	h.Server.SetChatFilter(func(text string) (string, error) {
+		if strings.Contains(text, "spam") {
+			return "", chat.ErrRejected
+		}
+		return text, nil
+	})
+	host := h.NewGame("Asha")[0]
+
+	tests := []struct {
+		name     string
+		text     string
+		wantCode protocol.ErrorCode
+	}{
+		{"too long", strings.Repeat("a", 11), protocol.ErrCodeMessageTooLong},
+		{"blank", " \t\n", protocol.ErrCodeBadMessage},
+		{"filtered", "buy spam", protocol.ErrCodeChatRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host.Send(protocol.TypeChatMessage, protocol.ChatMessageData{Text: tt.text})
+			if got := host.ExpectError(); got.Code != tt.wantCode {
+				t.Errorf("error %s %q, want %s", got.Code, got.Message, tt.wantCode)
+			}
+		})
+	}
+
+	// Nothing rejected was kept for the history
+	host.Send(protocol.TypeChatMessage, protocol.ChatMessageData{Text: "namaste"})
+	if msg := expectChat(t, host); msg.Text != "namaste" {
+		t.Errorf("got %q, want namaste", msg.Text)
+	}
+	history := expectHistory(t, h.Dial(host.GameID, host.PlayerID))
+	if len(history) != 1 || history[0].Text != "namaste" {
+		t.Errorf("history %+v, want only namaste", history)
+	}
+}
+
+// A player reconnecting is sent the most recent messages, oldest first
+func TestChatHistoryOnReconnect(t *testing.T) {
+	h := servertest.NewWithConfig(t, chatConfig())
+	clients := h.NewGame("Asha", "Ravi")
+	asha, ravi := clients[0], clients[1]
+
+	for i, text := range []string{"one", "two", "three"} {
+		sender := clients[i%2]
+		sender.Send(protocol.TypeChatMessage, protocol.ChatMessageData{Text: text})
+		expectChat(t, ravi)
+	}
+
+	ravi.Conn.Close()
+	history := expectHistory(t, h.Dial(asha.GameID, ravi.PlayerID))
+	if len(history) != 2 || history[0].Text != "two" || history[1].Text != "three" {
+		t.Fatalf("history %+v, want two and three", history)
+	}
+	if history[0].PlayerID != ravi.PlayerID || history[1].PlayerName != "Asha" {
+		t.Errorf("history %+v, want two from Ravi and three from Asha", history)
+	}
+}
@@ -0,0 +1,533 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/backplane"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// clusterTimeout bounds each backplane call made while serving a request
+const clusterTimeout = 5 * time.Second
+
+// maxClaimAttempts is how many fresh game IDs create tries when another
+// instance already owns the one it picked
+const maxClaimAttempts = 3
+
+// errOwnedElsewhere means another instance claimed a game ID first
+var errOwnedElsewhere = errors.New("game ID is owned by another instance")
+
+// Relay event kinds
+const (
+	relayOpen    = "open"    // Remote instance accepted a connection
+	relayMessage = "message" // A message from the player, or to them
+	relayClose   = "close"   // Either side closed the connection
+	relayPing    = "ping"    // The instance holding the WebSocket is still up
+	relayPong    = "pong"    // The owner is still up
+)
+
+// relayEvent carries one connection's traffic between the instance holding
+// the WebSocket and the instance that owns its game
+type relayEvent struct {
+	Kind       string `json:"kind"`
+	ConnID     string `json:"conn_id"`
+	GameID     string `json:"game_id,omitempty"`
+	PlayerID   string `json:"player_id,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Data       []byte `json:"data,omitempty"`
+}
+
+// connectRequest asks the owner whether a player may open a connection
+type connectRequest struct {
+	GameID   string `json:"game_id"`
+	PlayerID string `json:"player_id"`
+}
+
+// rpcReply is the answer to a request between instances
+type rpcReply struct {
+	Result json.RawMessage     `json:"result,omitempty"`
+	Error  *protocol.ErrorData `json:"error,omitempty"`
+}
+
+// remoteError is an error returned by the instance that owns a game
+type remoteError struct {
+	protocol.ErrorData
+}
+
+func (e *remoteError) Error() string { return e.Message }
+
+// cluster connects a server to the other instances sharing its backplane.
+// Each game is run by the instance that created it; other instances forward
+// joins to the owner and relay their WebSockets to it.
+type cluster struct {
+	bp     backplane.Backplane
+	id     string
+	server *Server
+
+	relays      map[string]*Client // Connection ID -> relay client, for games we own
+	unsubscribe []func()
+	stop        chan struct{} // Closed by close
+	mu          sync.Mutex
+}
+
+// UseBackplane joins the server to the instances sharing bp. Call it before
+// Start and before serving requests.
+func (s *Server) UseBackplane(bp backplane.Backplane) error {
+	c := &cluster{
+		bp:     bp,
+		id:     backplane.NewID(),
+		server: s,
+		relays: make(map[string]*Client),
+		stop:   make(chan struct{}),
+	}
+
+	serve := map[string]func([]byte) []byte{
+		c.topic("join"):  c.serveJoin,
+		c.topic("check"): c.serveCheck,
+	}
+	for topic, handler := range serve {
+		unsubscribe, err := backplane.Serve(bp, topic, handler)
+		if err != nil {
+			c.close()
+			return fmt.Errorf("serving %s: %w", topic, err)
+		}
+		c.unsubscribe = append(c.unsubscribe, unsubscribe)
+	}
+
+	unsubscribe, err := bp.Subscribe(c.topic("conn"), c.handleRelay)
+	if err != nil {
+		c.close()
+		return fmt.Errorf("subscribing to %s: %w", c.topic("conn"), err)
+	}
+	c.unsubscribe = append(c.unsubscribe, unsubscribe)
+
+	s.gameManager.OnRemove(c.release)
+	go c.expireRelays()
+	s.cluster = c
+	s.log.Info("joined backplane", "instance_id", c.id)
+	return nil
+}
+
+// InstanceID returns the server's ID on the backplane, or "" without one
+func (s *Server) InstanceID() string {
+	if s.cluster == nil {
+		return ""
+	}
+	return s.cluster.id
+}
+
+// topic returns the name of one of this instance's topics
+func (c *cluster) topic(name string) string {
+	return instanceTopic(c.id, name)
+}
+
+// instanceTopic returns the name of one of an instance's topics
+func instanceTopic(instanceID, name string) string {
+	return "instance." + instanceID + "." + name
+}
+
+// connTopic carries the owner's messages to one relayed connection
+func connTopic(connID string) string {
+	return "conn." + connID
+}
+
+// claim records this instance as the owner of a new game
+func (c *cluster) claim(gameID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	owner, err := c.bp.Claim(ctx, gameID, c.id)
+	if err != nil {
+		return err
+	}
+	if owner != c.id {
+		return errOwnedElsewhere
+	}
+	return nil
+}
+
+// release gives up ownership of a removed game. The game manager calls it
+// under its lock, so the backplane is not waited on.
+func (c *cluster) release(gameID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+
+		if err := c.bp.Release(ctx, gameID, c.id); err != nil {
+			c.server.log.Warn("releasing game failed", "game_id", gameID, "err", err)
+		}
+	}()
+}
+
+// close stops serving requests and gives up this instance's games
+func (c *cluster) close() {
+	close(c.stop)
+	for _, unsubscribe := range c.unsubscribe {
+		unsubscribe()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	for _, g := range c.server.gameManager.Games() {
+		c.bp.Release(ctx, g.ID, c.id)
+	}
+}
+
+// owner returns the other instance that owns a game, or ErrGameNotFound
+func (c *cluster) owner(ctx context.Context, gameID string) (string, error) {
+	owner, err := c.bp.Owner(ctx, gameID)
+	if err != nil {
+		return "", err
+	}
+	if owner == "" || owner == c.id {
+		return "", ErrGameNotFound
+	}
+	return owner, nil
+}
+
+// call sends a request to another instance and decodes its result into v
+func (c *cluster) call(ctx context.Context, owner, name string, req, v interface{}) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, clusterTimeout)
+	defer cancel()
+
+	msg, err := backplane.Request(ctx, c.bp, instanceTopic(owner, name), payload)
+	if err != nil {
+		return err
+	}
+
+	var reply rpcReply
+	if err := json.Unmarshal(msg, &reply); err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return &remoteError{*reply.Error}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, v)
+}
+
+// reply encodes the answer to a request from another instance
+func reply(result interface{}, err error) []byte {
+	var r rpcReply
+	if err != nil {
+		r.Error = &protocol.ErrorData{Code: errorCode(err), Message: err.Error()}
+	} else if result != nil {
+		r.Result, _ = json.Marshal(result)
+	}
+
+	msg, _ := json.Marshal(r)
+	return msg
+}
+
+// forwardJoin joins a game another instance owns
+func (c *cluster) forwardJoin(ctx context.Context, req JoinGameRequest) (JoinGameResponse, error) {
+	owner, err := c.owner(ctx, req.GameID)
+	if err != nil {
+		return JoinGameResponse{}, err
+	}
+
+	var resp JoinGameResponse
+	err = c.call(ctx, owner, "join", req, &resp)
+	return resp, err
+}
+
+// serveJoin joins a game for a player who asked another instance
+func (c *cluster) serveJoin(payload []byte) []byte {
+	var req JoinGameRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return reply(nil, err)
+	}
+	return reply(c.server.joinGame(req))
+}
+
+// checkConnect asks the owner of a game whether a player may connect, and
+// returns the owner
+func (c *cluster) checkConnect(ctx context.Context, gameID, playerID string) (string, error) {
+	owner, err := c.owner(ctx, gameID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.call(ctx, owner, "check", connectRequest{GameID: gameID, PlayerID: playerID}, nil); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// serveCheck answers checkConnect for a game this instance runs
+func (c *cluster) serveCheck(payload []byte) []byte {
+	var req connectRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return reply(nil, err)
+	}
+	return reply(nil, c.server.connectError(req.GameID, req.PlayerID))
+}
+
+// remoteConn relays a local WebSocket to the instance that owns its game
+type remoteConn struct {
+	cluster     *cluster
+	owner       string
+	connID      string
+	unsubscribe func()
+	lastHeard   atomic.Int64  // Unix nanoseconds of the owner's last event
+	done        chan struct{} // Closed when the WebSocket closes
+}
+
+// relay starts relaying a registered client to the game's owner, which
+// sends the initial state. On failure the client is closed.
+func (c *cluster) relay(client *Client, owner string) messageHandler {
+	rc := &remoteConn{cluster: c, owner: owner, connID: backplane.NewID(), done: make(chan struct{})}
+	rc.lastHeard.Store(time.Now().UnixNano())
+
+	hub := c.server.hub
+	unsubscribe, err := c.bp.Subscribe(connTopic(rc.connID), func(payload []byte) {
+		var ev relayEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return
+		}
+		rc.lastHeard.Store(time.Now().UnixNano())
+
+		switch ev.Kind {
+		case relayMessage:
+			hub.BroadcastEach(client.GameID, func(other *Client) []byte {
+				if other != client {
+					return nil
+				}
+				return ev.Data
+			})
+		case relayClose:
+			hub.closeWhere(client.GameID, func(other *Client) bool { return other == client })
+		}
+	})
+	if err != nil {
+		client.log.Warn("relaying connection failed", "owner", owner, "err", err)
+		rc.unsubscribe = func() {}
+		hub.closeWhere(client.GameID, func(other *Client) bool { return other == client })
+		return rc
+	}
+	rc.unsubscribe = unsubscribe
+
+	rc.publish(relayEvent{
+		Kind:       relayOpen,
+		GameID:     client.GameID,
+		PlayerID:   client.ID,
+		IP:         client.IP,
+		RemoteAddr: client.remoteAddr,
+	}, client)
+	go rc.keepalive(client)
+	return rc
+}
+
+// keepalive pings the owner so it knows this instance still holds the
+// WebSocket, and closes the client once the owner has gone quiet
+func (rc *remoteConn) keepalive(client *Client) {
+	limits := rc.cluster.server.connLimits
+	ticker := time.NewTicker(limits.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, rc.lastHeard.Load())) > limits.pongTimeout {
+				client.log.Warn("owner stopped answering, closing relayed connection", "owner", rc.owner)
+				rc.cluster.server.hub.closeWhere(client.GameID, func(other *Client) bool { return other == client })
+				return
+			}
+			rc.publish(relayEvent{Kind: relayPing}, client)
+		case <-rc.done:
+			return
+		}
+	}
+}
+
+// HandleMessage forwards a player's message to the owner
+func (rc *remoteConn) HandleMessage(client *Client, message []byte) {
+	rc.publish(relayEvent{Kind: relayMessage, Data: message}, client)
+}
+
+// HandleDisconnect tells the owner the connection closed
+func (rc *remoteConn) HandleDisconnect(client *Client) {
+	close(rc.done)
+	rc.unsubscribe()
+	rc.publish(relayEvent{Kind: relayClose}, client)
+}
+
+// publish sends an event to the owner's relay topic
+func (rc *remoteConn) publish(ev relayEvent, client *Client) {
+	ev.ConnID = rc.connID
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := rc.cluster.bp.Publish(ctx, instanceTopic(rc.owner, "conn"), msg); err != nil {
+		client.log.Warn("relaying to owner failed", "owner", rc.owner, "kind", ev.Kind, "err", err)
+	}
+}
+
+// handleRelay acts on connections other instances relay to games we own
+func (c *cluster) handleRelay(payload []byte) {
+	var ev relayEvent
+	if err := json.Unmarshal(payload, &ev); err != nil || ev.ConnID == "" {
+		return
+	}
+
+	switch ev.Kind {
+	case relayOpen:
+		c.openRelay(ev)
+
+	case relayMessage:
+		c.mu.Lock()
+		client := c.relays[ev.ConnID]
+		c.mu.Unlock()
+		if client != nil {
+			client.messagesIn.Add(1)
+			client.bytesIn.Add(int64(len(ev.Data)))
+			client.lastSeen.Store(time.Now().UnixNano())
+			c.server.wsHandler.HandleMessage(client, ev.Data)
+		}
+
+	case relayPing:
+		c.mu.Lock()
+		client := c.relays[ev.ConnID]
+		c.mu.Unlock()
+		if client == nil {
+			// We closed it, or never had it; the WebSocket should go too
+			c.publishToConn(ev.ConnID, relayEvent{Kind: relayClose})
+			return
+		}
+		client.lastSeen.Store(time.Now().UnixNano())
+		c.publishToConn(ev.ConnID, relayEvent{Kind: relayPong})
+
+	case relayClose:
+		if client := c.closeRelay(ev.ConnID); client != nil {
+			client.log.Info("relayed client disconnected", "conn_id", ev.ConnID)
+		}
+	}
+}
+
+// closeRelay disconnects a relayed client and returns it, or nil if it
+// was already gone
+func (c *cluster) closeRelay(connID string) *Client {
+	c.mu.Lock()
+	client := c.relays[connID]
+	delete(c.relays, connID)
+	c.mu.Unlock()
+
+	if client != nil {
+		c.server.hub.Unregister(client)
+		c.server.wsHandler.HandleDisconnect(client)
+	}
+	return client
+}
+
+// expireRelays drops relayed clients whose instance has stopped pinging,
+// so players behind an instance that died don't stay seated as connected
+func (c *cluster) expireRelays() {
+	limits := c.server.connLimits
+	ticker := time.NewTicker(limits.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			var silent []string
+			c.mu.Lock()
+			for connID, client := range c.relays {
+				if now.Sub(time.Unix(0, client.lastSeen.Load())) > limits.pongTimeout {
+					silent = append(silent, connID)
+				}
+			}
+			c.mu.Unlock()
+
+			for _, connID := range silent {
+				if client := c.closeRelay(connID); client != nil {
+					client.log.Warn("relayed client timed out", "conn_id", connID)
+				}
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// openRelay registers a relayed connection as a local client whose
+// messages are published back to the instance holding the WebSocket
+func (c *cluster) openRelay(ev relayEvent) {
+	s := c.server
+	if err := s.connectError(ev.GameID, ev.PlayerID); err != nil {
+		s.log.Info("relayed connection rejected", "game_id", ev.GameID, "player_id", ev.PlayerID, "err", err)
+		c.publishToConn(ev.ConnID, relayEvent{Kind: relayClose})
+		return
+	}
+
+	client := NewClient(ev.PlayerID, ev.GameID, nil, s.connLimits, s.log)
+	client.IP = ev.IP
+	client.remoteAddr = ev.RemoteAddr
+	client.log.Info("relayed client connected", "conn_id", ev.ConnID, "remote_addr", client.remoteAddr, "ip", client.IP)
+
+	if !s.trackConn(client) {
+		c.publishToConn(ev.ConnID, relayEvent{Kind: relayClose})
+		return
+	}
+	c.mu.Lock()
+	c.relays[ev.ConnID] = client
+	c.mu.Unlock()
+
+	s.wsHandler.HandleConnect(client)
+	go func() {
+		defer s.conns.Done()
+		c.relayPump(ev.ConnID, client)
+	}()
+}
+
+// relayPump publishes a relay client's messages until the hub closes it,
+// then tells the instance holding the WebSocket to close it too
+func (c *cluster) relayPump(connID string, client *Client) {
+	for msg := range client.Send {
+		c.publishToConn(connID, relayEvent{Kind: relayMessage, Data: msg})
+		client.messagesOut.Add(1)
+		client.bytesOut.Add(int64(len(msg)))
+	}
+	c.publishToConn(connID, relayEvent{Kind: relayClose})
+}
+
+// publishToConn sends an event to a relayed connection
+func (c *cluster) publishToConn(connID string, ev relayEvent) {
+	ev.ConnID = connID
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := c.bp.Publish(ctx, connTopic(connID), msg); err != nil {
+		c.server.log.Warn("relaying to connection failed", "conn_id", connID, "kind", ev.Kind, "err", err)
+	}
+}
+
+// checkBackplane fails if the backplane doesn't answer
+func (c *cluster) checkBackplane() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	_, err := c.bp.Owner(ctx, "healthcheck")
+	return err
+}
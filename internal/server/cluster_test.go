@@ -0,0 +1,178 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/backplane"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+const backplaneToken = "test-backplane-token-0123456789"
+
+// newTCPCluster starts two servers that share a backplane hub over TCP,
+// each with its own connection as separate processes would have. It
+// returns the connections too, so a test can cut one off.
+func newTCPCluster(t *testing.T, cfg *config.Config) ([]*servertest.Harness, []*backplane.Remote) {
+	t.Helper()
+
+	hub, err := backplane.Listen("127.0.0.1:0", backplaneToken)
+	if err != nil {
+		t.Fatalf("start hub: %v", err)
+	}
+	t.Cleanup(func() { hub.Close() })
+
+	harnesses := make([]*servertest.Harness, 2)
+	remotes := make([]*backplane.Remote, 2)
+	for i := range harnesses {
+		bp, err := backplane.Dial(hub.Addr().String(), backplaneToken)
+		if err != nil {
+			t.Fatalf("dial hub: %v", err)
+		}
+		// Registered first so it runs after the server shuts down
+		t.Cleanup(func() { bp.Close() })
+		harnesses[i] = servertest.NewWithBackplane(t, cfg, bp)
+		remotes[i] = bp
+	}
+	return harnesses, remotes
+}
+
+// keepaliveConfig pings relayed connections often, so a dead instance is
+// noticed within the test's timeout
+func keepaliveConfig() *config.Config {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	cfg.PingInterval, cfg.PongTimeout = 50*time.Millisecond, 300*time.Millisecond
+	return cfg
+}
+
+// connections returns how many connections a player has in a state
+func connections(state *protocol.GameState, playerID string) int {
+	for _, p := range state.Players {
+		if p.ID == playerID {
+			return p.Connections
+		}
+	}
+	return -1
+}
+
+// expectClosed waits for the server to close a client's WebSocket
+func expectClosed(t *testing.T, c *servertest.Client) {
+	t.Helper()
+
+	c.Conn.SetReadDeadline(time.Now().Add(servertest.DefaultTimeout))
+	for {
+		if _, _, err := c.Conn.ReadMessage(); err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				t.Fatalf("player %s: connection still open", c.PlayerID)
+			}
+			return
+		}
+	}
+}
+
+func TestClusterGame(t *testing.T) {
+	tests := []struct {
+		name  string
+		start func(t *testing.T) []*servertest.Harness
+	}{
+		{"memory", func(t *testing.T) []*servertest.Harness { return servertest.NewCluster(t, 2) }},
+		{"tcp", func(t *testing.T) []*servertest.Harness {
+			cfg := config.Default()
+			cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+			harnesses, _ := newTCPCluster(t, cfg)
+			return harnesses
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := tt.start(t)
+			a, b := cluster[0], cluster[1]
+			if a.Server.InstanceID() == b.Server.InstanceID() {
+				t.Fatalf("both instances have ID %s", a.Server.InstanceID())
+			}
+
+			// The game lives on a; Ravi joins through b and Meera plays through b
+			created := a.CreateGame("Asha")
+			ravi := b.JoinGame(created.GameID, "Ravi")
+			meera := a.JoinGame(created.GameID, "Meera")
+
+			clients := []*servertest.Client{
+				a.Connect(created.GameID, created.PlayerID),
+				b.Connect(created.GameID, ravi.PlayerID),
+				b.Connect(created.GameID, meera.PlayerID),
+			}
+			states := servertest.PlayGame(clients)
+
+			final := states[0]
+			if final.State != models.StateFinished || len(final.Players) != 3 {
+				t.Fatalf("host sees state %s with %d players, want finished with 3", final.State, len(final.Players))
+			}
+			for i, s := range states[1:] {
+				for j, p := range s.Players {
+					if p.Score != final.Players[j].Score {
+						t.Errorf("client %d sees %s with %d points, host sees %d", i+1, p.ID, p.Score, final.Players[j].Score)
+					}
+				}
+			}
+
+			// Joining an unknown game through b still fails cleanly
+			resp := b.PostJSON("/api/join", server.JoinGameRequest{GameID: "BABA-BABA-BABA", PlayerName: "Zoya"})
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("join unknown game through b: status %d, want 404", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// An instance that dies while relaying a player must not leave them
+// seated as connected on the owner, and the owner dying closes the
+// relayed WebSocket
+func TestClusterInstanceDies(t *testing.T) {
+	t.Run("relaying instance", func(t *testing.T) {
+		cluster, remotes := newTCPCluster(t, keepaliveConfig())
+		a, b := cluster[0], cluster[1]
+
+		created := a.CreateGame("Asha")
+		ravi := b.JoinGame(created.GameID, "Ravi")
+		host := a.Connect(created.GameID, created.PlayerID)
+		b.Connect(created.GameID, ravi.PlayerID)
+		host.ExpectState(func(s *protocol.GameState) bool { return connections(s, ravi.PlayerID) == 1 })
+
+		// b can no longer reach the hub, as if its process had crashed
+		remotes[1].Close()
+
+		// A player leaving doesn't push a new state, so ask until it shows
+		deadline := time.Now().Add(servertest.DefaultTimeout)
+		for {
+			host.Send(protocol.TypeGetState, protocol.GetStateData{})
+			state := host.ExpectState(func(*protocol.GameState) bool { return true })
+			if connections(state, ravi.PlayerID) == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Ravi is still connected on the owner after b died")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		cluster, remotes := newTCPCluster(t, keepaliveConfig())
+		a, b := cluster[0], cluster[1]
+
+		created := a.CreateGame("Asha")
+		ravi := b.JoinGame(created.GameID, "Ravi")
+		client := b.Connect(created.GameID, ravi.PlayerID)
+
+		remotes[0].Close()
+
+		expectClosed(t, client)
+	})
+}
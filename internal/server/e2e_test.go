@@ -0,0 +1,498 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+// checkHands fails if a state shows the viewer anyone's hand but their own
+func checkHands(t *testing.T, viewer *servertest.Client, state *protocol.GameState) {
+	t.Helper()
+
+	for _, p := range state.Players {
+		switch {
+		case p.ID == viewer.PlayerID:
+			if !p.IsMe {
+				t.Errorf("%s: own player not marked is_me", viewer.PlayerID)
+			}
+			if len(p.Hand) != p.HandSize {
+				t.Errorf("%s: own hand has %d cards, hand_size %d", viewer.PlayerID, len(p.Hand), p.HandSize)
+			}
+		case p.IsMe:
+			t.Errorf("%s: %s marked is_me", viewer.PlayerID, p.ID)
+		case p.Hand != nil:
+			t.Errorf("%s can see %s's hand %v", viewer.PlayerID, p.ID, p.Hand)
+		}
+	}
+}
+
+func TestHiddenHands(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi", "Meera")
+
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	for turn := 1; turn <= 3; turn++ {
+		for _, c := range clients {
+			state := c.ExpectState(func(s *protocol.GameState) bool {
+				return s.State == models.StatePlaying && s.Turn == turn
+			})
+			checkHands(t, c, state)
+			for _, p := range state.Players {
+				if want := 9 - (turn - 1); p.HandSize != want {
+					t.Errorf("turn %d: %s sees %s with %d cards, want %d", turn, c.PlayerID, p.ID, p.HandSize, want)
+				}
+			}
+		}
+		for _, c := range clients {
+			c.Send(protocol.TypeSelectCard, protocol.SelectCardData{CardIndex: 0})
+		}
+	}
+}
+
+func TestHiddenHandsInRawMessages(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi")
+
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	clients[1].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+
+	// A snapshot must not carry the other hand in any field, not just the
+	// one the client decodes
+	clients[1].Send(protocol.TypeGetState, protocol.GetStateData{})
+	msg := clients[1].Expect(protocol.TypeStateSnapshot)
+
+	var raw struct {
+		State struct {
+			Players []map[string]json.RawMessage `json:"players"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal(msg.Data, &raw); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	for _, p := range raw.State.Players {
+		var id string
+		json.Unmarshal(p["id"], &id)
+		_, ok := p["hand"]
+		switch {
+		case id == clients[1].PlayerID && !ok:
+			t.Errorf("snapshot for %s is missing their own hand", id)
+		case id != clients[1].PlayerID && ok:
+			t.Errorf("snapshot for %s includes %s's hand", clients[1].PlayerID, id)
+		}
+	}
+}
+
+func TestWebSocketErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		players     int
+		started     bool
+		sender      int // Index of the client that sends
+		messageType string
+		data        interface{}
+		wantCode    protocol.ErrorCode
+		wantMessage string
+	}{
+		{
+			name: "start by guest", players: 2, sender: 1,
+			messageType: protocol.TypeStartGame, data: protocol.StartGameData{},
+			wantCode: protocol.ErrCodeNotHost, wantMessage: "only host can start the game",
+		},
+		{
+			name: "start alone", players: 1,
+			messageType: protocol.TypeStartGame, data: protocol.StartGameData{},
+			wantCode: protocol.ErrCodeNotEnoughPlayers, wantMessage: "cannot start game: need 2-5 players",
+		},
+		{
+			name: "start twice", players: 2, started: true,
+			messageType: protocol.TypeStartGame, data: protocol.StartGameData{},
+			wantCode: protocol.ErrCodeGameStarted, wantMessage: "game already started",
+		},
+		{
+			name: "select before start", players: 2,
+			messageType: protocol.TypeSelectCard, data: protocol.SelectCardData{CardIndex: 0},
+			wantCode: protocol.ErrCodeGameNotPlaying, wantMessage: "game is not in playing state",
+		},
+		{
+			name: "select past hand", players: 2, started: true,
+			messageType: protocol.TypeSelectCard, data: protocol.SelectCardData{CardIndex: 10},
+			wantCode: protocol.ErrCodeInvalidCardIndex, wantMessage: "invalid card index",
+		},
+		{
+			name: "negative card index", players: 2, started: true,
+			messageType: protocol.TypeSelectCard, data: protocol.SelectCardData{CardIndex: -1},
+			wantCode: protocol.ErrCodeInvalidCardIndex, wantMessage: "invalid card index",
+		},
+		{
+			name: "kick by guest", players: 2, sender: 1,
+			messageType: protocol.TypeKickPlayer, data: protocol.KickPlayerData{PlayerID: "anyone"},
+			wantCode: protocol.ErrCodeNotHost, wantMessage: "only host can kick players",
+		},
+		{
+			name: "kick stranger", players: 2,
+			messageType: protocol.TypeKickPlayer, data: protocol.KickPlayerData{PlayerID: "nobody"},
+			wantCode: protocol.ErrCodePlayerNotFound, wantMessage: game.ErrPlayerNotFound.Error(),
+		},
+		{
+			name: "unknown type", players: 1,
+			messageType: "shuffle_deck", data: struct{}{},
+			wantCode: protocol.ErrCodeUnknownType, wantMessage: `unknown message type "shuffle_deck"`,
+		},
+		{
+			name: "bad payload", players: 2,
+			messageType: protocol.TypeSelectCard, data: map[string]string{"card_index": "first"},
+			wantCode: protocol.ErrCodeBadMessage, wantMessage: "invalid select_card data",
+		},
+	}
+
+	names := []string{"Asha", "Ravi", "Meera"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := servertest.New(t)
+			clients := h.NewGame(names[:tt.players]...)
+			if tt.started {
+				clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+				clients[tt.sender].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+			}
+
+			clients[tt.sender].Send(tt.messageType, tt.data)
+
+			got := clients[tt.sender].ExpectError()
+			if got.Code != tt.wantCode || got.Message != tt.wantMessage {
+				t.Errorf("error %s %q, want %s %q", got.Code, got.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSelectTwiceInOneTurn(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi")
+
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	clients[0].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+
+	clients[0].Send(protocol.TypeSelectCard, protocol.SelectCardData{CardIndex: 0})
+	clients[0].Send(protocol.TypeSelectCard, protocol.SelectCardData{CardIndex: 0})
+
+	got := clients[0].ExpectError()
+	if got.Code != protocol.ErrCodeNotYourTurn || got.Message != game.ErrNotYourTurn.Error() {
+		t.Errorf("error %s %q, want %s %q", got.Code, got.Message, protocol.ErrCodeNotYourTurn, game.ErrNotYourTurn)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	h := servertest.New(t)
+	started := h.NewGame("Asha", "Ravi")
+	started[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	started[0].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+
+	full := h.CreateGame("Host")
+	for i := 1; i < 5; i++ {
+		h.JoinGame(full.GameID, "Guest")
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		body        interface{}
+		wantStatus  int
+		wantCode    protocol.ErrorCode
+		wantMessage string
+	}{
+		{
+			name: "join unknown game", path: "/api/join",
+			body:       server.JoinGameRequest{GameID: "BABA-BABA-BABA", PlayerName: "Ravi"},
+			wantStatus: http.StatusNotFound, wantCode: protocol.ErrCodeGameNotFound, wantMessage: server.ErrGameNotFound.Error(),
+		},
+		{
+			name: "join without game ID", path: "/api/join",
+			body:       server.JoinGameRequest{PlayerName: "Ravi"},
+			wantStatus: http.StatusBadRequest, wantCode: protocol.ErrCodeBadRequest, wantMessage: "game ID is required",
+		},
+		{
+			name: "join started game", path: "/api/join",
+			body:       server.JoinGameRequest{GameID: started[0].GameID, PlayerName: "Meera"},
+			wantStatus: http.StatusConflict, wantCode: protocol.ErrCodeGameStarted, wantMessage: game.ErrGameStarted.Error(),
+		},
+		{
+			name: "join full game", path: "/api/join",
+			body:       server.JoinGameRequest{GameID: full.GameID, PlayerName: "Meera"},
+			wantStatus: http.StatusConflict, wantCode: protocol.ErrCodeGameFull, wantMessage: server.ErrGameFull.Error(),
+		},
+		{
+			name: "create with bad body", path: "/api/create",
+			body:       "not an object",
+			wantStatus: http.StatusBadRequest, wantCode: protocol.ErrCodeBadRequest, wantMessage: "invalid request body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.PostJSON(tt.path, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			var got protocol.ErrorData
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode error body: %v", err)
+			}
+			if got.Code != tt.wantCode || got.Message != tt.wantMessage {
+				t.Errorf("error %s %q, want %s %q", got.Code, got.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestFinalScores(t *testing.T) {
+	for players := 2; players <= 5; players++ {
+		names := []string{"Asha", "Ravi", "Meera", "Kabir", "Zoya"}[:players]
+		t.Run(names[players-1], func(t *testing.T) {
+			h := servertest.New(t)
+			states := servertest.PlayGame(h.NewGame(names...))
+
+			// Every player sees the same scores
+			final := states[0]
+			for i, s := range states[1:] {
+				for j, p := range s.Players {
+					if p.Score != final.Players[j].Score {
+						t.Errorf("client %d sees %s with %d points, host sees %d", i+1, p.ID, p.Score, final.Players[j].Score)
+					}
+				}
+			}
+
+			// Re-score the last round from the final tableaux, which still
+			// hold every pudding from earlier rounds
+			g := models.NewGame(final.ID, final.HostID)
+			for _, p := range final.Players {
+				player := models.NewPlayer(p.ID, p.Name)
+				player.PlayedCards = p.PlayedCards
+				g.Players[p.ID] = player
+			}
+			game.ScoreRound(g)
+
+			puddings := make(map[string]int)
+			for _, p := range final.Players {
+				puddings[p.ID] = 0
+				for _, card := range p.PlayedCards {
+					if card.Type == models.GurabJamun {
+						puddings[p.ID]++
+					}
+				}
+			}
+			bonus := game.ScoreMajority(puddings, game.GulabJamunRule)
+
+			for _, p := range final.Players {
+				if len(p.RoundScores) != 3 {
+					t.Fatalf("%s has round scores %v, want 3", p.ID, p.RoundScores)
+				}
+				if want := g.Players[p.ID].RoundScores[0]; p.RoundScores[2] != want {
+					t.Errorf("%s scored %d in round 3, want %d", p.ID, p.RoundScores[2], want)
+				}
+
+				sum := 0
+				for _, s := range p.RoundScores {
+					sum += s
+				}
+				if p.Score != sum+bonus[p.ID] {
+					t.Errorf("%s has %d points, want %d from rounds + %d for gulab jamun", p.ID, p.Score, sum, bonus[p.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestStartErrorNamesConfiguredLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	cfg.MinPlayers, cfg.MaxPlayers = 3, 4
+
+	h := servertest.NewWithConfig(t, cfg)
+	clients := h.NewGame("Asha", "Ravi")
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+
+	got := clients[0].ExpectError()
+	if want := "cannot start game: need 3-4 players"; got.Code != protocol.ErrCodeNotEnoughPlayers || got.Message != want {
+		t.Errorf("error %s %q, want %s %q", got.Code, got.Message, protocol.ErrCodeNotEnoughPlayers, want)
+	}
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 1, 2, 0
+	h := servertest.NewWithConfig(t, cfg)
+
+	created := h.CreateGame("Asha")
+	h.JoinGame(created.GameID, "Ravi")
+	h.JoinGame(created.GameID, "Meera")
+
+	tests := []struct {
+		name      string
+		path      string
+		body      interface{}
+		wantRetry string // Seconds until the next token
+	}{
+		{"create", "/api/create", server.CreateGameRequest{PlayerName: "Kabir"}, "60"},
+		{"join", "/api/join", server.JoinGameRequest{GameID: created.GameID, PlayerName: "Kabir"}, "30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.PostJSON(tt.path, tt.body)
+			if resp.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+			}
+
+			retry := resp.Header.Get("Retry-After")
+			if retry != tt.wantRetry {
+				t.Errorf("Retry-After %q, want %q", retry, tt.wantRetry)
+			}
+
+			var got protocol.ErrorData
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode error body: %v", err)
+			}
+			if want := "too many requests, retry in " + retry + "s"; got.Code != protocol.ErrCodeRateLimited || got.Message != want {
+				t.Errorf("error %s %q, want %s %q", got.Code, got.Message, protocol.ErrCodeRateLimited, want)
+			}
+		})
+	}
+}
+
+func TestWebSocketRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 3
+	h := servertest.NewWithConfig(t, cfg)
+	host := h.NewGame("Asha")[0]
+
+	// Connecting spent two of the three tokens; a burst of ten runs out
+	// long before any refill
+	for i := 0; i < 10; i++ {
+		host.Send(protocol.TypeGetState, protocol.GetStateData{})
+	}
+
+	got := host.ExpectError()
+	if got.Code != protocol.ErrCodeRateLimited || !strings.HasPrefix(got.Message, "too many messages, retry in ") {
+		t.Errorf("error %s %q, want %s %q", got.Code, got.Message, protocol.ErrCodeRateLimited, "too many messages, retry in ...")
+	}
+
+	// The connection stays open; messages go through again once tokens
+	// refill
+	time.Sleep(time.Second)
+	host.Send(protocol.TypeGetState, protocol.GetStateData{})
+	host.Expect(protocol.TypeStateSnapshot)
+}
+
+func TestPrivateRoomJoin(t *testing.T) {
+	h := servertest.New(t)
+
+	resp := h.PostJSON("/api/create", server.CreateGameRequest{PlayerName: "Asha", Password: "masala dosa"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create private game: status %d", resp.StatusCode)
+	}
+	var created server.CreateGameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+
+	host := h.Connect(created.GameID, created.PlayerID)
+	host.Send(protocol.TypeCreateInvite, protocol.CreateInviteData{})
+	var invite protocol.InviteData
+	if err := json.Unmarshal(host.Expect(protocol.TypeInvite).Data, &invite); err != nil {
+		t.Fatalf("decode invite: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		req        server.JoinGameRequest
+		wantStatus int
+		wantCode   protocol.ErrorCode // Empty for a successful join
+	}{
+		{"no password", server.JoinGameRequest{PlayerName: "Ravi"}, http.StatusForbidden, protocol.ErrCodePasswordRequired},
+		{"wrong password", server.JoinGameRequest{PlayerName: "Ravi", Password: "plain idli"}, http.StatusForbidden, protocol.ErrCodeWrongPassword},
+		{"tampered invite", server.JoinGameRequest{PlayerName: "Ravi", Invite: invite.Token + "x"}, http.StatusForbidden, protocol.ErrCodeInvalidInvite},
+		{"password", server.JoinGameRequest{PlayerName: "Ravi", Password: "masala dosa"}, http.StatusOK, ""},
+		{"invite skips the password", server.JoinGameRequest{PlayerName: "Meera", Invite: invite.Token}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.GameID = created.GameID
+			resp := h.PostJSON("/api/join", tt.req)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var got protocol.ErrorData
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode error body: %v", err)
+			}
+			if got.Code != tt.wantCode {
+				t.Errorf("error %s %q, want %s", got.Code, got.Message, tt.wantCode)
+			}
+		})
+	}
+}
+
+// A client that misses a patch sees the next one out of order, sends
+// resync and continues from a fresh snapshot
+func TestResyncAfterMissedPatch(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi")
+	asha := clients[0]
+
+	// nextPatch connects a new player, which patches everyone's state
+	nextPatch := func(name string) protocol.StatePatchData {
+		t.Helper()
+
+		joined := h.JoinGame(asha.GameID, name)
+		h.Connect(asha.GameID, joined.PlayerID)
+		var patch protocol.StatePatchData
+		if err := json.Unmarshal(asha.Expect(protocol.TypeStatePatch).Data, &patch); err != nil {
+			t.Fatalf("decode patch: %v", err)
+		}
+		return patch
+	}
+
+	missed := nextPatch("Meera") // Lost on the way
+	applied := missed.Seq - 1
+	next := nextPatch("Zoya")
+	if next.Seq == applied+1 {
+		t.Fatalf("patch %d follows state %d; nothing was missed", next.Seq, applied)
+	}
+
+	asha.Send(protocol.TypeResync, protocol.ResyncData{LastSeq: applied})
+	var snapshot protocol.StateSnapshotData
+	if err := json.Unmarshal(asha.Expect(protocol.TypeStateSnapshot).Data, &snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snapshot.Seq <= next.Seq {
+		t.Errorf("snapshot seq %d, want after patch %d", snapshot.Seq, next.Seq)
+	}
+	if len(snapshot.State.Players) != 4 {
+		t.Errorf("snapshot has %d players, want 4", len(snapshot.State.Players))
+	}
+	for _, p := range snapshot.State.Players {
+		if p.Connections != 1 {
+			t.Errorf("snapshot shows %s with %d connections, want 1", p.Name, p.Connections)
+		}
+	}
+
+	if after := nextPatch("Dev"); after.Seq != snapshot.Seq+1 {
+		t.Errorf("patch %d after snapshot %d, want patches to follow it", after.Seq, snapshot.Seq)
+	}
+}
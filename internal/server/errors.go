@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/access"
+	"github.com/aiplaybookin/tiffin-go/internal/chat"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// Errors for requests the server itself turns away
+var (
+	errShuttingDown       = errors.New("server is shutting down")
+	errTooManyConnections = errors.New("too many open connections for this player")
+)
+
+// errorCodes maps engine and manager errors to protocol error codes
+var errorCodes = []struct {
+	err  error
+	code protocol.ErrorCode
+}{
+	{ErrGameNotFound, protocol.ErrCodeGameNotFound},
+	{ErrGameFull, protocol.ErrCodeGameFull},
+	{ErrTooManyGames, protocol.ErrCodeServerFull},
+	{ErrTooManyPlayers, protocol.ErrCodeServerFull},
+	{ErrNotHost, protocol.ErrCodeNotHost},
+	{ErrGameFinished, protocol.ErrCodeGameFinished},
+	{ErrPasswordRequired, protocol.ErrCodePasswordRequired},
+	{ErrWrongPassword, protocol.ErrCodeWrongPassword},
+	{access.ErrInvalidInvite, protocol.ErrCodeInvalidInvite},
+	{access.ErrInviteExpired, protocol.ErrCodeInviteExpired},
+	{errShuttingDown, protocol.ErrCodeShuttingDown},
+	{errTooManyConnections, protocol.ErrCodeTooManyConnections},
+	{game.ErrGameStarted, protocol.ErrCodeGameStarted},
+	{game.ErrCannotStart, protocol.ErrCodeNotEnoughPlayers},
+	{game.ErrPlayerNotFound, protocol.ErrCodePlayerNotFound},
+	{game.ErrNotYourTurn, protocol.ErrCodeNotYourTurn},
+	{game.ErrInvalidCardIndex, protocol.ErrCodeInvalidCardIndex},
+	{game.ErrGameNotPlaying, protocol.ErrCodeGameNotPlaying},
+	{game.ErrNotAllSelected, protocol.ErrCodeInvalidAction},
+	{game.ErrNoPlayers, protocol.ErrCodeInvalidAction},
+	{chat.ErrEmpty, protocol.ErrCodeBadMessage},
+	{chat.ErrTooLong, protocol.ErrCodeMessageTooLong},
+	{chat.ErrRejected, protocol.ErrCodeChatRejected},
+	{chat.ErrUnknownReaction, protocol.ErrCodeBadMessage},
+}
+
+// errorCode returns the protocol error code for an error
+func errorCode(err error) protocol.ErrorCode {
+	var remote *remoteError
+	if errors.As(err, &remote) {
+		return remote.Code
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return protocol.ErrCodeInternal
+}
+
+// httpStatus returns the HTTP status for a protocol error code
+func httpStatus(code protocol.ErrorCode) int {
+	switch code {
+	case protocol.ErrCodeBadRequest, protocol.ErrCodeBadMessage:
+		return http.StatusBadRequest
+	case protocol.ErrCodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case protocol.ErrCodeGameNotFound, protocol.ErrCodePlayerNotFound:
+		return http.StatusNotFound
+	case protocol.ErrCodeGameFull, protocol.ErrCodeGameStarted, protocol.ErrCodeGameFinished:
+		return http.StatusConflict
+	case protocol.ErrCodeUnauthorized:
+		return http.StatusUnauthorized
+	case protocol.ErrCodeNotHost, protocol.ErrCodeOriginNotAllowed, protocol.ErrCodePasswordRequired,
+		protocol.ErrCodeWrongPassword, protocol.ErrCodeInvalidInvite:
+		return http.StatusForbidden
+	case protocol.ErrCodeInviteExpired:
+		return http.StatusGone
+	case protocol.ErrCodeTooManyConnections, protocol.ErrCodeRateLimited:
+		return http.StatusTooManyRequests
+	case protocol.ErrCodeServerFull, protocol.ErrCodeShuttingDown:
+		return http.StatusServiceUnavailable
+	case protocol.ErrCodeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// writeError writes a JSON error response with the status for its code
+func writeError(w http.ResponseWriter, code protocol.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(code))
+	json.NewEncoder(w).Encode(protocol.ErrorData{Code: code, Message: message})
+}
+
+// writeRateLimited rejects a request that exceeded a rate limit, telling the
+// client when to retry
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, protocol.ErrCodeRateLimited, fmt.Sprintf("too many requests, retry in %ds", seconds))
+}
+
+// writeErr writes a JSON error response for an engine or manager error
+func writeErr(w http.ResponseWriter, err error) {
+	writeError(w, errorCode(err), err.Error())
+}
@@ -4,105 +4,543 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"log/slog"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/aiplaybookin/tiffin-go/internal/access"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
 	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/roomcode"
 )
 
-// GameManager manages all active games
+// Errors returned by the game manager
+var (
+	ErrGameNotFound   = errors.New("game not found")
+	ErrGameFull       = errors.New("game is full")
+	ErrTooManyGames   = errors.New("server has reached its game limit")
+	ErrTooManyPlayers = errors.New("server has reached its player limit")
+	ErrNotHost        = errors.New("only the host can do that")
+	ErrGameFinished   = errors.New("game has already finished")
+
+	ErrPasswordRequired = errors.New("this room needs a password or invite")
+	ErrWrongPassword    = errors.New("wrong room password")
+)
+
+// Pass is what a player offers to get into a private room. A valid invite
+// admits them without the password.
+type Pass struct {
+	Password string
+	Invite   string
+}
+
+// GameManager manages all active games. Its lock guards the set of games
+// and the player count; each game's own lock guards the game. Methods that
+// change a game expect the caller to hold that game's lock (see LockGame),
+// which is taken before the manager's.
 type GameManager struct {
-	games map[string]*models.Game
-	mu    sync.RWMutex
+	games            map[string]*models.Game
+	players          int // Players across all games
+	maxGames         int // 0 = unlimited
+	maxTotalPlayers  int // 0 = unlimited
+	minPlayers       int
+	maxPlayers       int
+	lobbyIdleTimeout time.Duration
+	gameIdleTimeout  time.Duration
+	invites          *access.Signer
+	inviteTTL        time.Duration
+	newCode          func() string // Room code generator, replaced in tests
+	log              *slog.Logger
+	onRemove         func(gameID string) // Called under mu; must not block
+	mu               sync.RWMutex        // Guards games and players
 }
 
 // NewGameManager creates a new game manager
-func NewGameManager() *GameManager {
+func NewGameManager(cfg *config.Config, logger *slog.Logger) *GameManager {
 	return &GameManager{
-		games: make(map[string]*models.Game),
+		games:            make(map[string]*models.Game),
+		log:              logger,
+		maxGames:         cfg.MaxGames,
+		maxTotalPlayers:  cfg.MaxTotalPlayers,
+		minPlayers:       cfg.MinPlayers,
+		maxPlayers:       cfg.MaxPlayers,
+		lobbyIdleTimeout: cfg.LobbyIdleTimeout,
+		gameIdleTimeout:  cfg.GameIdleTimeout,
+		invites:          access.NewSigner(),
+		inviteTTL:        cfg.InviteTTL,
+		newCode:          roomcode.New,
 	}
 }
 
-// CreateGame creates a new game room
-func (gm *GameManager) CreateGame(hostID, hostName string) (*models.Game, error) {
+// CreateGame creates a new game room, private if password is set
+func (gm *GameManager) CreateGame(hostID, hostName, password string) (*models.Game, error) {
+	var hash []byte
+	if password != "" {
+		hash = access.HashPassword(password)
+	}
+
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
-	gameID := generateGameID()
+	if gm.maxGames > 0 && len(gm.games) >= gm.maxGames {
+		return nil, ErrTooManyGames
+	}
+	if gm.playerLimitReached() {
+		return nil, ErrTooManyPlayers
+	}
+
+	gameID := gm.newGameID()
 	game := models.NewGame(gameID, hostID)
+	game.MinPlayers = gm.minPlayers
+	game.MaxPlayers = gm.maxPlayers
+	game.Password = hash
 
 	// Add host as first player
 	player := models.NewPlayer(hostID, hostName)
 	game.Players[hostID] = player
 
 	gm.games[gameID] = game
+	gm.players++
+	gm.log.Info("game created", "game_id", gameID, "player_id", hostID, "player_name", hostName, "private", game.Private())
 	return game, nil
 }
 
-// JoinGame adds a player to an existing game
+// JoinGame adds a player to an existing game. A private game's pass must
+// already have been checked with Admit. Caller holds the game's lock.
 func (gm *GameManager) JoinGame(gameID, playerID, playerName string) (*models.Game, error) {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
-	game, exists := gm.games[gameID]
+	g, exists := gm.games[gameID]
 	if !exists {
-		return nil, errors.New("game not found")
+		return nil, ErrGameNotFound
 	}
 
-	if game.State != models.StateWaiting {
-		return nil, errors.New("game already started")
+	if g.State != models.StateWaiting {
+		return nil, game.ErrGameStarted
 	}
 
-	if len(game.Players) >= game.MaxPlayers {
-		return nil, errors.New("game is full")
+	if len(g.Players) >= g.MaxPlayers {
+		return nil, ErrGameFull
 	}
 
 	// Check if player already in game
-	if _, exists := game.Players[playerID]; exists {
-		return game, nil // Already joined
+	if _, exists := g.Players[playerID]; exists {
+		return g, nil // Already joined
+	}
+
+	if gm.playerLimitReached() {
+		return nil, ErrTooManyPlayers
 	}
 
 	player := models.NewPlayer(playerID, playerName)
-	game.Players[playerID] = player
+	g.Players[playerID] = player
+	g.LastActivity = time.Now()
+	gm.players++
+	gm.log.Info("player joined", "game_id", gameID, "player_id", playerID, "player_name", playerName, "players", len(g.Players))
 
-	return game, nil
+	return g, nil
 }
 
-// LeaveGame removes a player from a game
-func (gm *GameManager) LeaveGame(gameID, playerID string) error {
+// Admit checks a pass against a game before joining it. Password hashing
+// is slow, so it runs without holding the manager's lock, and callers
+// should not hold the game's either. The hash never changes once set.
+func (gm *GameManager) Admit(gameID string, pass Pass) error {
+	gm.mu.RLock()
+	g, exists := gm.games[gameID]
+	var hash []byte
+	if exists {
+		hash = g.Password
+	}
+	gm.mu.RUnlock()
+
+	if !exists {
+		return ErrGameNotFound
+	}
+
+	if pass.Invite != "" {
+		return gm.invites.Verify(gameID, pass.Invite, time.Now())
+	}
+	if len(hash) == 0 {
+		return nil
+	}
+	if pass.Password == "" {
+		return ErrPasswordRequired
+	}
+	if !access.CheckPassword(hash, pass.Password) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// Invite creates an invite token for a game that is still waiting for
+// players. Only the host may invite. Caller holds the game's lock.
+func (gm *GameManager) Invite(gameID, hostID string) (string, time.Time, error) {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
-	game, exists := gm.games[gameID]
+	g, exists := gm.games[gameID]
 	if !exists {
-		return errors.New("game not found")
+		return "", time.Time{}, ErrGameNotFound
 	}
 
-	delete(game.Players, playerID)
+	if g.HostID != hostID {
+		return "", time.Time{}, ErrNotHost
+	}
 
-	// If no players left or host left while waiting, delete game
-	if len(game.Players) == 0 || (game.HostID == playerID && game.State == models.StateWaiting) {
-		delete(gm.games, gameID)
+	if g.State != models.StateWaiting {
+		return "", time.Time{}, game.ErrGameStarted
+	}
+
+	now := time.Now()
+	expires := now.Add(gm.inviteTTL)
+	g.LastActivity = now
+	gm.log.Info("invite created", "game_id", gameID, "player_id", hostID, "expires_at", expires)
+	return gm.invites.Sign(gameID, expires), expires, nil
+}
+
+// LeaveResult describes what happened when a player left a game
+type LeaveResult struct {
+	PlayerName    string
+	NewHostID     string // Set when the host left and another player took over
+	ReplacedByBot bool   // Player left mid-game and a bot now plays their hand
+	GameEnded     bool   // No human players remained, so the game was removed
+}
+
+// LeaveGame removes a player from a game. Players leaving a game in progress
+// are replaced by a bot so the remaining players can finish. Caller holds
+// the game's lock.
+func (gm *GameManager) LeaveGame(gameID, playerID string) (*LeaveResult, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	g, exists := gm.games[gameID]
+	if !exists {
+		return nil, ErrGameNotFound
+	}
+
+	player, exists := g.Players[playerID]
+	if !exists || player.IsBot {
+		return nil, game.ErrPlayerNotFound
+	}
+
+	result := &LeaveResult{PlayerName: player.Name}
+	g.LastActivity = time.Now()
+
+	if g.State == models.StatePlaying || g.State == models.StateScoring {
+		player.IsBot = true
+		result.ReplacedByBot = true
+	} else {
+		delete(g.Players, playerID)
+		gm.players--
+	}
+
+	log := gm.log.With("game_id", gameID, "player_id", playerID)
+	log.Info("player left", "replaced_by_bot", result.ReplacedByBot)
+
+	// If no humans are left there is nobody to play with
+	if g.HumanCount() == 0 {
+		gm.removeGame(gameID)
+		result.GameEnded = true
+		log.Info("game removed", "reason", "no players left")
+		return result, nil
+	}
+
+	if g.HostID == playerID {
+		g.HostID = g.NextHostID()
+		result.NewHostID = g.HostID
+		log.Info("host changed", "new_host_id", g.HostID, "reason", "left")
+	}
+
+	return result, nil
+}
+
+// MarkConnected records that a player opened a connection. Caller holds
+// the game's lock.
+func (gm *GameManager) MarkConnected(gameID, playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if g, exists := gm.games[gameID]; exists {
+		if player, exists := g.Players[playerID]; exists {
+			if !player.IsConnected() {
+				player.ConnectedAt = time.Now()
+			}
+			player.Connections++
+			gm.log.Debug("player connected", "game_id", gameID, "player_id", playerID, "connections", player.Connections)
+		}
+	}
+}
+
+// MarkDisconnected records that one of a player's connections closed. When
+// their last connection closes and they were the host, the host moves to
+// whoever has been connected the longest; the new host's ID is returned.
+// Caller holds the game's lock.
+func (gm *GameManager) MarkDisconnected(gameID, playerID string) string {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	g, exists := gm.games[gameID]
+	if !exists {
+		return ""
+	}
+
+	player, exists := g.Players[playerID]
+	if !exists || player.Connections == 0 {
+		return ""
+	}
+
+	player.Connections--
+	gm.log.Debug("player disconnected", "game_id", gameID, "player_id", playerID, "connections", player.Connections)
+	if player.IsConnected() {
+		return ""
+	}
+	player.ConnectedAt = time.Time{}
+
+	if g.HostID != playerID || g.State == models.StateFinished {
+		return ""
+	}
+
+	next := g.NextHostID()
+	if next == "" || !g.Players[next].IsConnected() {
+		return ""
+	}
+
+	g.HostID = next
+	gm.log.Info("host changed", "game_id", gameID, "player_id", playerID, "new_host_id", next, "reason", "disconnected")
+	return next
+}
+
+// TransferHost hands the host role from the current host to another
+// player. Caller holds the game's lock.
+func (gm *GameManager) TransferHost(gameID, hostID, newHostID string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	g, exists := gm.games[gameID]
+	if !exists {
+		return ErrGameNotFound
+	}
+
+	if g.HostID != hostID {
+		return ErrNotHost
 	}
 
+	player, exists := g.Players[newHostID]
+	if !exists || player.IsBot {
+		return game.ErrPlayerNotFound
+	}
+
+	g.HostID = newHostID
+	g.LastActivity = time.Now()
+	gm.log.Info("host changed", "game_id", gameID, "player_id", hostID, "new_host_id", newHostID, "reason", "transferred")
 	return nil
 }
 
-// GetGame retrieves a game by ID
+// EndGame finishes a game early, keeping the scores it has so far. Caller
+// holds the game's lock.
+func (gm *GameManager) EndGame(gameID string) (*models.Game, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	g, exists := gm.games[gameID]
+	if !exists {
+		return nil, ErrGameNotFound
+	}
+	if g.State == models.StateFinished {
+		return nil, ErrGameFinished
+	}
+
+	now := time.Now()
+	previous := g.State
+	g.State = models.StateFinished
+	g.FinishedAt = now
+	g.LastActivity = now
+	gm.log.Info("game ended early", "game_id", gameID, "state", previous, "round", g.Round)
+	return g, nil
+}
+
+// OnRemove registers fn to be called whenever a game is removed. fn runs
+// with the manager locked, so it must not block or call back in.
+func (gm *GameManager) OnRemove(fn func(gameID string)) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	gm.onRemove = fn
+}
+
+// RemoveGame deletes a game and its players, logging why. Caller holds the
+// game's lock.
+func (gm *GameManager) RemoveGame(gameID, reason string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if _, exists := gm.games[gameID]; !exists {
+		return ErrGameNotFound
+	}
+
+	gm.removeGame(gameID)
+	gm.log.Info("game removed", "game_id", gameID, "reason", reason)
+	return nil
+}
+
+// Touch records activity on a game so it isn't expired. Caller holds the
+// game's lock.
+func (gm *GameManager) Touch(gameID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if game, exists := gm.games[gameID]; exists {
+		game.LastActivity = time.Now()
+	}
+}
+
+// ExpireIdle removes games that have been idle too long and returns their IDs.
+// Games in progress use the game idle timeout; waiting and finished games use
+// the lobby idle timeout. It locks each game in turn, so a game isn't expired
+// halfway through an action.
+func (gm *GameManager) ExpireIdle(now time.Time) []string {
+	expired := []string{}
+	for _, g := range gm.Games() {
+		if gm.expireIfIdle(g, now) {
+			expired = append(expired, g.ID)
+		}
+	}
+	return expired
+}
+
+// expireIfIdle removes one game if it has been idle too long
+func (gm *GameManager) expireIfIdle(g *models.Game, now time.Time) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	timeout := gm.lobbyIdleTimeout
+	if g.State == models.StatePlaying || g.State == models.StateScoring {
+		timeout = gm.gameIdleTimeout
+	}
+	if g.IdleFor(now) < timeout {
+		return false
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.games[g.ID] != g {
+		return false // Already removed
+	}
+	gm.removeGame(g.ID)
+	gm.log.Info("game removed", "game_id", g.ID, "reason", "idle", "state", g.State, "idle", g.IdleFor(now).Round(time.Second))
+	return true
+}
+
+// Counts returns the number of games and players
+func (gm *GameManager) Counts() (games, players int) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	return len(gm.games), gm.players
+}
+
+// CountByState returns how many games are in each state. States change
+// during game actions, so it takes each game's lock in turn.
+func (gm *GameManager) CountByState() map[models.GameState]int {
+	counts := make(map[models.GameState]int)
+	for _, g := range gm.Games() {
+		g.Lock()
+		counts[g.State]++
+		g.Unlock()
+	}
+	return counts
+}
+
+// removeGame deletes a game and its players from the totals; caller holds
+// gm.mu and the game's lock
+func (gm *GameManager) removeGame(gameID string) {
+	if game, exists := gm.games[gameID]; exists {
+		gm.players -= len(game.Players)
+		delete(gm.games, gameID)
+		if gm.onRemove != nil {
+			gm.onRemove(gameID)
+		}
+	}
+}
+
+// playerLimitReached reports whether another player would exceed the cap; caller holds gm.mu
+func (gm *GameManager) playerLimitReached() bool {
+	return gm.maxTotalPlayers > 0 && gm.players >= gm.maxTotalPlayers
+}
+
+// Games returns every game, oldest first
+func (gm *GameManager) Games() []*models.Game {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	games := make([]*models.Game, 0, len(gm.games))
+	for _, g := range gm.games {
+		games = append(games, g)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].CreatedAt.Equal(games[j].CreatedAt) {
+			return games[i].CreatedAt.Before(games[j].CreatedAt)
+		}
+		return games[i].ID < games[j].ID
+	})
+	return games
+}
+
+// LockGame takes a game's lock and returns the function that releases it.
+// A game that doesn't exist, or is removed while waiting for the lock, has
+// nothing to guard, so a no-op is returned; the caller's next lookup will
+// report it missing.
+func (gm *GameManager) LockGame(gameID string) (unlock func()) {
+	g, err := gm.GetGame(gameID)
+	if err != nil {
+		return func() {}
+	}
+
+	g.Lock()
+	if current, err := gm.GetGame(gameID); err != nil || current != g {
+		g.Unlock()
+		return func() {}
+	}
+	return g.Unlock
+}
+
+// GetGame retrieves a game by ID. Read or change it only while holding its
+// lock.
 func (gm *GameManager) GetGame(gameID string) (*models.Game, error) {
 	gm.mu.RLock()
 	defer gm.mu.RUnlock()
 
 	game, exists := gm.games[gameID]
 	if !exists {
-		return nil, errors.New("game not found")
+		return nil, ErrGameNotFound
 	}
 
 	return game, nil
 }
 
-// generateGameID creates a random 6-character game ID
-func generateGameID() string {
-	bytes := make([]byte, 3)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
+// newGameID returns a room code no current game uses; caller holds gm.mu.
+// Codes are random, so this almost always takes one try. With a backplane,
+// another instance may still hold the code; createGame claims it to check.
+func (gm *GameManager) newGameID() string {
+	for {
+		id := gm.newCode()
+		if _, taken := gm.games[id]; !taken {
+			return id
+		}
+	}
+}
+
+// newPlayerID returns a random (version 4) UUID
+func newPlayerID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	id := hex.EncodeToString(b)
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
 }
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+)
+
+// A new game never gets the code of a game still running
+func TestNewGameIDSkipsCodesInUse(t *testing.T) {
+	gm := NewGameManager(config.Default(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	codes := []string{"KAZU-MEBU-DATE", "KAZU-MEBU-DATE", "KAZU-MEBU-DATE", "BABA-BABA-BABA"}
+	calls := 0
+	gm.newCode = func() string {
+		calls++
+		return codes[calls-1]
+	}
+
+	first, err := gm.CreateGame("p1", "Asha", "")
+	if err != nil {
+		t.Fatalf("CreateGame: %v", err)
+	}
+	second, err := gm.CreateGame("p2", "Ravi", "")
+	if err != nil {
+		t.Fatalf("CreateGame: %v", err)
+	}
+
+	if first.ID != "KAZU-MEBU-DATE" || second.ID != "BABA-BABA-BABA" {
+		t.Errorf("games %s and %s, want KAZU-MEBU-DATE and BABA-BABA-BABA", first.ID, second.ID)
+	}
+	if calls != 4 {
+		t.Errorf("%d codes drawn, want 4: one, then three for the second game", calls)
+	}
+}
@@ -1,10 +1,25 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/access"
+	"github.com/aiplaybookin/tiffin-go/internal/chat"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/ratelimit"
+	"github.com/aiplaybookin/tiffin-go/internal/roomcode"
+	"github.com/gorilla/websocket"
 )
 
 // Server represents the HTTP server
@@ -12,29 +27,150 @@ type Server struct {
 	hub         *Hub
 	gameManager *GameManager
 	wsHandler   *WSHandler
+	upgrader    websocket.Upgrader
+	log         *slog.Logger
+	adminToken  string   // Empty disables the admin API
+	cluster     *cluster // nil unless UseBackplane was called
+
+	maxConnections int // Per player
+	connLimits     connLimits
+	createLimiter  *ratelimit.Limiter // Per IP; nil when unlimited
+	joinLimiter    *ratelimit.Limiter
+
+	reapInterval time.Duration
+	lastReap     atomic.Int64 // UnixNano of the reaper's last run, for health checks
+	stopReaper   chan struct{}
+	shuttingDown atomic.Bool
+	connMu       sync.Mutex     // Orders conns.Add against Shutdown starting to wait
+	conns        sync.WaitGroup // One per open WebSocket
 }
 
-// NewServer creates a new server
-func NewServer() *Server {
-	hub := NewHub()
-	gameManager := NewGameManager()
-	wsHandler := NewWSHandler(hub, gameManager)
+// NewServer creates a new server that logs to logger
+func NewServer(cfg *config.Config, logger *slog.Logger) *Server {
+	serverMetrics := newServerMetrics()
+	hub := NewHub(serverMetrics, logger)
+	gameManager := NewGameManager(cfg, logger)
+	wsHandler := NewWSHandler(hub, gameManager, cfg, logger)
+	serverMetrics.registerGauges(wsHandler)
 
 	return &Server{
 		hub:         hub,
 		gameManager: gameManager,
 		wsHandler:   wsHandler,
+		upgrader:    newUpgrader(cfg.AllowedOrigins),
+		log:         logger,
+		adminToken:  cfg.AdminToken,
+
+		maxConnections: cfg.MaxConnections,
+		connLimits: connLimits{
+			pingInterval:   cfg.PingInterval,
+			pongTimeout:    cfg.PongTimeout,
+			maxMessageSize: cfg.MaxMessageSize,
+		},
+		createLimiter: ratelimit.PerMinute(cfg.CreateRate),
+		joinLimiter:   ratelimit.PerMinute(cfg.JoinRate),
+
+		reapInterval: cfg.ReapInterval,
+		stopReaper:   make(chan struct{}),
 	}
 }
 
 // Start starts the server
 func (s *Server) Start() {
-	go s.hub.Run()
+	s.lastReap.Store(time.Now().UnixNano())
+	go s.runReaper()
+}
+
+// Shutdown stops accepting new games, tells every connected client the
+// server is going away and waits for their connections to drain.
+//
+// Games only live in memory, so there is nothing to snapshot yet.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.connMu.Lock()
+	started := s.shuttingDown.CompareAndSwap(false, true)
+	s.connMu.Unlock()
+	if !started {
+		return nil
+	}
+	close(s.stopReaper)
+
+	s.hub.BroadcastAll(protocol.TypeServerShuttingDown, protocol.ServerShuttingDownData{
+		Message: "server is shutting down",
+	})
+	s.hub.CloseAll()
+	if s.cluster != nil {
+		defer s.cluster.close()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConnectionStats returns a health snapshot of every open WebSocket
+func (s *Server) ConnectionStats() []ClientStats {
+	return s.hub.Stats()
+}
+
+// SetChatFilter replaces the hook that cleans or rejects chat text, for
+// example to plug in a profanity service. nil relays chat unchanged.
+func (s *Server) SetChatFilter(filter chat.Filter) {
+	s.wsHandler.SetChatFilter(filter)
+}
+
+// rejectIfShuttingDown answers with a shutting_down error once Shutdown has begun
+func (s *Server) rejectIfShuttingDown(w http.ResponseWriter) bool {
+	if s.shuttingDown.Load() {
+		writeError(w, protocol.ErrCodeShuttingDown, "server is shutting down")
+		return true
+	}
+	return false
+}
+
+// rejectIfRateLimited answers with a rate_limited error if the request's IP
+// has no tokens left in l
+func (s *Server) rejectIfRateLimited(w http.ResponseWriter, r *http.Request, l *ratelimit.Limiter) bool {
+	if ok, retryAfter := l.Allow(clientIP(r)); !ok {
+		s.log.Info("request rate limited", "path", r.URL.Path, "ip", clientIP(r))
+		writeRateLimited(w, retryAfter)
+		return true
+	}
+	return false
+}
+
+// clientIP returns the IP address a request came from
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RegisterRoutes registers the API and WebSocket routes on a mux
+func (s *Server) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/create", s.HandleCreateGame)
+	mux.HandleFunc("/api/join", s.HandleJoinGame)
+	mux.HandleFunc("/ws", s.HandleWebSocket)
+	mux.Handle("/metrics", s.hub.metrics.registry.Handler())
+	mux.HandleFunc("/healthz", s.HandleHealthz)
+	mux.HandleFunc("/readyz", s.HandleReadyz)
+	s.registerAdminRoutes(mux)
 }
 
 // CreateGameRequest represents a request to create a game
 type CreateGameRequest struct {
 	PlayerName string `json:"player_name"`
+	Password   string `json:"password,omitempty"` // Makes the room private
 }
 
 // CreateGameResponse represents the response from creating a game
@@ -47,6 +183,8 @@ type CreateGameResponse struct {
 type JoinGameRequest struct {
 	GameID     string `json:"game_id"`
 	PlayerName string `json:"player_name"`
+	Password   string `json:"password,omitempty"` // For private rooms
+	Invite     string `json:"invite,omitempty"`   // Token from an invite link; replaces the password
 }
 
 // JoinGameResponse represents the response from joining a game
@@ -58,13 +196,17 @@ type JoinGameResponse struct {
 // HandleCreateGame handles creating a new game
 func (s *Server) HandleCreateGame(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.rejectIfShuttingDown(w) || s.rejectIfRateLimited(w, r, s.createLimiter) {
 		return
 	}
 
 	var req CreateGameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, protocol.ErrCodeBadRequest, "invalid request body")
 		return
 	}
 
@@ -72,39 +214,71 @@ func (s *Server) HandleCreateGame(w http.ResponseWriter, r *http.Request) {
 		req.PlayerName = "Player"
 	}
 
-	// Generate player ID
-	playerID := generateGameID()
-
-	game, err := s.gameManager.CreateGame(playerID, req.PlayerName)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if len(req.Password) > access.MaxPasswordLength {
+		writeError(w, protocol.ErrCodeBadRequest, fmt.Sprintf("password must be at most %d bytes", access.MaxPasswordLength))
 		return
 	}
 
-	resp := CreateGameResponse{
-		GameID:   game.ID,
-		PlayerID: playerID,
+	resp, err := s.createGame(req.PlayerName, req.Password)
+	if err != nil {
+		s.log.Info("create game rejected", "ip", clientIP(r), "err", err)
+		writeErr(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// createGame creates a game hosted by a new player. With a backplane, the
+// game is claimed for this instance, and an ID another instance already
+// owns is given up and another tried.
+func (s *Server) createGame(playerName, password string) (CreateGameResponse, error) {
+	// Generate player ID
+	playerID := newPlayerID()
+
+	for attempt := 1; ; attempt++ {
+		game, err := s.gameManager.CreateGame(playerID, playerName, password)
+		if err != nil {
+			return CreateGameResponse{}, err
+		}
+
+		if s.cluster != nil {
+			if err := s.cluster.claim(game.ID); err != nil {
+				unlock := s.gameManager.LockGame(game.ID)
+				s.gameManager.RemoveGame(game.ID, "claim failed")
+				unlock()
+				if errors.Is(err, errOwnedElsewhere) && attempt < maxClaimAttempts {
+					continue
+				}
+				return CreateGameResponse{}, err
+			}
+		}
+
+		return CreateGameResponse{GameID: game.ID, PlayerID: playerID}, nil
+	}
+}
+
 // HandleJoinGame handles joining an existing game
 func (s *Server) HandleJoinGame(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.rejectIfShuttingDown(w) || s.rejectIfRateLimited(w, r, s.joinLimiter) {
 		return
 	}
 
 	var req JoinGameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, protocol.ErrCodeBadRequest, "invalid request body")
 		return
 	}
 
+	req.GameID = roomcode.Normalize(req.GameID)
 	if req.GameID == "" {
-		http.Error(w, "Game ID is required", http.StatusBadRequest)
+		writeError(w, protocol.ErrCodeBadRequest, "game ID is required")
 		return
 	}
 
@@ -112,71 +286,148 @@ func (s *Server) HandleJoinGame(w http.ResponseWriter, r *http.Request) {
 		req.PlayerName = "Player"
 	}
 
+	resp, err := s.joinGame(req)
+	if errors.Is(err, ErrGameNotFound) && s.cluster != nil {
+		resp, err = s.cluster.forwardJoin(r.Context(), req)
+	}
+	if err != nil {
+		s.log.Info("join game rejected", "game_id", req.GameID, "ip", clientIP(r), "err", err)
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// joinGame seats a new player in a game this instance runs. The pass is
+// checked first; the join itself holds the game's lock like every other
+// change to its players.
+func (s *Server) joinGame(req JoinGameRequest) (JoinGameResponse, error) {
+	if err := s.gameManager.Admit(req.GameID, Pass{Password: req.Password, Invite: req.Invite}); err != nil {
+		return JoinGameResponse{}, err
+	}
+
 	// Generate player ID
-	playerID := generateGameID()
+	playerID := newPlayerID()
+
+	defer s.gameManager.LockGame(req.GameID)()
 
 	game, err := s.gameManager.JoinGame(req.GameID, playerID, req.PlayerName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return JoinGameResponse{}, err
 	}
 
 	// Broadcast player joined
-	s.hub.BroadcastToGame(game.ID, "player_joined", map[string]string{
-		"player_id":   playerID,
-		"player_name": req.PlayerName,
+	s.hub.BroadcastToGame(game.ID, protocol.TypePlayerJoined, protocol.PlayerJoinedData{
+		PlayerID:   playerID,
+		PlayerName: req.PlayerName,
 	})
 
-	resp := JoinGameResponse{
-		GameID:   game.ID,
-		PlayerID: playerID,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return JoinGameResponse{GameID: game.ID, PlayerID: playerID}, nil
 }
 
 // HandleWebSocket handles WebSocket connections
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	gameID := r.URL.Query().Get("game_id")
+	if s.rejectIfShuttingDown(w) {
+		return
+	}
+
+	if !s.upgrader.CheckOrigin(r) {
+		s.log.Warn("WebSocket origin rejected", "ip", clientIP(r), "origin", r.Header.Get("Origin"))
+		writeError(w, protocol.ErrCodeOriginNotAllowed, "origin not allowed")
+		return
+	}
+
+	gameID := roomcode.Normalize(r.URL.Query().Get("game_id"))
 	playerID := r.URL.Query().Get("player_id")
 
 	if gameID == "" || playerID == "" {
-		http.Error(w, "game_id and player_id required", http.StatusBadRequest)
+		writeError(w, protocol.ErrCodeBadRequest, "game_id and player_id required")
 		return
 	}
 
-	// Verify game and player exist
-	game, err := s.gameManager.GetGame(gameID)
+	// Games another instance owns are relayed to it
+	owner := ""
+	err := s.connectError(gameID, playerID)
+	if errors.Is(err, ErrGameNotFound) && s.cluster != nil {
+		owner, err = s.cluster.checkConnect(r.Context(), gameID, playerID)
+	}
 	if err != nil {
-		http.Error(w, "Game not found", http.StatusNotFound)
+		writeErr(w, err)
 		return
 	}
 
-	if _, exists := game.Players[playerID]; !exists {
-		http.Error(w, "Player not found", http.StatusNotFound)
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.Warn("WebSocket upgrade failed", "game_id", gameID, "player_id", playerID, "err", err)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+	client := NewClient(playerID, gameID, conn, s.connLimits, s.log)
+	client.IP = clientIP(r)
+	if !s.trackConn(client) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"),
+			time.Now().Add(writeTimeout))
+		conn.Close()
 		return
 	}
+	client.log.Info("client connected", "remote_addr", client.remoteAddr, "ip", client.IP, "owner", owner)
+
+	// Send initial game state and recent chat before the read pump can see
+	// a drop, so the disconnect always follows the connect. The owner does
+	// this for relayed connections.
+	var handler messageHandler = s.wsHandler
+	if owner != "" {
+		handler = s.cluster.relay(client, owner)
+	} else {
+		s.wsHandler.HandleConnect(client)
+	}
+
+	// Start client pumps
+	go func() {
+		defer s.conns.Done()
+		client.writePump()
+	}()
+	go client.readPump(s.hub, handler)
+}
+
+// trackConn registers a new client and counts it among the connections
+// Shutdown waits for. It reports false once Shutdown has begun, since
+// Shutdown may already have closed every client and be waiting.
+func (s *Server) trackConn(client *Client) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.shuttingDown.Load() {
+		return false
+	}
+	s.conns.Add(1)
+	s.hub.Register(client)
+	return true
+}
 
-	client := &Client{
-		ID:     playerID,
-		GameID: gameID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
+// connectError returns why a player may not open another connection to a
+// game this instance runs, or nil
+func (s *Server) connectError(gameID, playerID string) error {
+	if s.shuttingDown.Load() {
+		return errShuttingDown
 	}
 
-	s.hub.register <- client
+	defer s.gameManager.LockGame(gameID)()
 
-	// Start client pumps
-	go client.writePump()
-	go client.readPump(s.hub, s.wsHandler)
+	g, err := s.gameManager.GetGame(gameID)
+	if err != nil {
+		return err
+	}
 
-	// Send initial game state
-	s.wsHandler.broadcastGameState(gameID)
+	if player, exists := g.Players[playerID]; !exists || player.IsBot {
+		return game.ErrPlayerNotFound
+	}
+
+	if s.hub.PlayerConnections(gameID, playerID) >= s.maxConnections {
+		return errTooManyConnections
+	}
+	return nil
 }
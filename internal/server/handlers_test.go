@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// Joins and connect checks touch a game's players while game actions and
+// state broadcasts read them; run with -race
+func TestJoinAndConnectHoldGameLock(t *testing.T) {
+	cfg := config.Default()
+	s := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	created, err := s.createGame("Host", "")
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+	host := NewClient(created.PlayerID, created.GameID, nil, s.connLimits, s.log)
+	s.hub.Register(host)
+	go func() {
+		for range host.Send {
+		}
+	}()
+	t.Cleanup(func() { s.hub.Unregister(host) })
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			unlock := s.gameManager.LockGame(created.GameID)
+			s.wsHandler.broadcastGameState(created.GameID)
+			unlock()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			joined, err := s.joinGame(JoinGameRequest{GameID: created.GameID, PlayerName: "Guest"})
+			if err != nil {
+				continue // Full until the leave below catches up
+			}
+			unlock := s.gameManager.LockGame(created.GameID)
+			s.gameManager.LeaveGame(created.GameID, joined.PlayerID)
+			unlock()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			s.connectError(created.GameID, created.PlayerID)
+			s.connectError(created.GameID, "nobody")
+		}
+	}()
+
+	wg.Wait()
+}
+
+// A connection dropped straight after the upgrade must still be counted
+// out, or the player looks connected forever
+func TestDroppedConnectionIsCountedOut(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxConnections = 100
+	s := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	created, err := s.createGame("Host", "")
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?game_id=" + created.GameID + "&player_id=" + created.PlayerID
+	for i := 0; i < 50; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn.Close()
+	}
+
+	connections := func() int {
+		defer s.gameManager.LockGame(created.GameID)()
+		g, _ := s.gameManager.GetGame(created.GameID)
+		return g.Players[created.PlayerID].Connections
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for connections() != 0 || s.hub.PlayerConnections(created.GameID, created.PlayerID) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("player has %d connections after every drop", connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Connections opened while Shutdown begins are either refused or closed
+// and waited for; run with -race
+func TestShutdownWaitsForTrackedConns(t *testing.T) {
+	s := NewServer(config.Default(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := NewClient(newPlayerID(), "BABA-BABA-BABA", nil, s.connLimits, s.log)
+			if !s.trackConn(client) {
+				return
+			}
+			go func() {
+				defer s.conns.Done()
+				for range client.Send {
+				}
+			}()
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	wg.Wait()
+
+	if s.trackConn(NewClient("late", "BABA-BABA-BABA", nil, s.connLimits, s.log)) {
+		t.Error("connection tracked after shutdown")
+	}
+	if n := s.hub.ClientCount(); n != 0 {
+		t.Errorf("%d clients left after shutdown", n)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// healthTimeout is how long a check may block before it counts as failed
+const healthTimeout = 2 * time.Second
+
+// HealthResponse reports the result of each check and the server's load
+type HealthResponse struct {
+	Status  string            `json:"status"` // "ok" or "unavailable"
+	Checks  map[string]string `json:"checks"` // Check name -> "ok" or the failure
+	Games   int               `json:"games"`
+	Players int               `json:"players"`
+	Clients int               `json:"clients"` // Open WebSocket connections
+}
+
+// healthCheck is one named probe; it returns nil when healthy
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// livenessChecks detect a wedged process. They cover the locks every
+// request goes through, each game's lock and the reaper goroutine.
+func (s *Server) livenessChecks() []healthCheck {
+	return []healthCheck{
+		{"hub", func() error { s.hub.ClientCount(); return nil }},
+		{"game_manager", func() error { s.gameManager.Counts(); return nil }},
+		{"games", func() error {
+			for _, g := range s.gameManager.Games() {
+				g.Lock()
+				g.Unlock()
+			}
+			return nil
+		}},
+		{"reaper", s.checkReaper},
+	}
+}
+
+// readinessChecks decide whether the server should be sent new players
+func (s *Server) readinessChecks() []healthCheck {
+	checks := append(s.livenessChecks(), healthCheck{"accepting", func() error {
+		if s.shuttingDown.Load() {
+			return errors.New("shutting down")
+		}
+		return nil
+	}})
+	if s.cluster != nil {
+		checks = append(checks, healthCheck{"backplane", s.cluster.checkBackplane})
+	}
+	return checks
+}
+
+// checkReaper fails if the reaper has missed several ticks. It stops on
+// shutdown, which is expected, so it isn't checked then.
+func (s *Server) checkReaper() error {
+	if s.shuttingDown.Load() {
+		return nil
+	}
+
+	last := time.Unix(0, s.lastReap.Load())
+	if since := time.Since(last); since > 3*s.reapInterval {
+		return fmt.Errorf("last ran %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// HandleHealthz reports whether the process is alive
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, s.livenessChecks())
+}
+
+// HandleReadyz reports whether the server is ready for new players. It goes
+// unavailable as soon as a graceful shutdown begins.
+func (s *Server) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, s.readinessChecks())
+}
+
+// writeHealth runs checks and answers 200 if they all pass, otherwise 503
+func (s *Server) writeHealth(w http.ResponseWriter, r *http.Request, checks []healthCheck) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, protocol.ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	resp := HealthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+	for _, c := range checks {
+		if err := runCheck(c.check); err != nil {
+			resp.Checks[c.name] = err.Error()
+			resp.Status = "unavailable"
+		} else {
+			resp.Checks[c.name] = "ok"
+		}
+	}
+
+	// Only read the counts if the locks behind them are free
+	if resp.Checks["hub"] == "ok" && resp.Checks["game_manager"] == "ok" {
+		resp.Games, resp.Players = s.gameManager.Counts()
+		resp.Clients = s.hub.ClientCount()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != "ok" {
+		if !s.shuttingDown.Load() {
+			s.log.Warn("health check failed", "path", r.URL.Path, "checks", resp.Checks)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// runCheck runs check, failing it if it blocks for longer than healthTimeout
+func runCheck(check func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- check() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(healthTimeout):
+		return fmt.Errorf("no response after %s", healthTimeout)
+	}
+}
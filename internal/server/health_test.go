@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+// getHealth fetches a health endpoint and decodes its report
+func getHealth(t *testing.T, h *servertest.Harness, path string) (int, server.HealthResponse) {
+	t.Helper()
+
+	resp, err := h.HTTP.Client().Get(h.HTTP.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var health server.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return resp.StatusCode, health
+}
+
+func TestHealth(t *testing.T) {
+	h := servertest.New(t)
+	h.NewGame("Asha", "Ravi")
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		t.Run(path, func(t *testing.T) {
+			status, health := getHealth(t, h, path)
+			if status != http.StatusOK || health.Status != "ok" {
+				t.Errorf("status %d %q, want 200 ok: %v", status, health.Status, health.Checks)
+			}
+			for name, result := range health.Checks {
+				if result != "ok" {
+					t.Errorf("check %s: %s", name, result)
+				}
+			}
+			if health.Games != 1 || health.Players != 2 || health.Clients != 2 {
+				t.Errorf("%d games, %d players, %d clients, want 1, 2 and 2", health.Games, health.Players, health.Clients)
+			}
+
+			resp := h.PostJSON(path, nil)
+			expectHTTPError(t, resp, http.StatusMethodNotAllowed, protocol.ErrCodeMethodNotAllowed)
+		})
+	}
+}
+
+// Once shutdown begins the server stops being ready, so a load balancer
+// sends new players elsewhere, but it is still alive
+func TestReadyzDuringShutdown(t *testing.T) {
+	h := servertest.New(t)
+	shutdown(t, h)
+
+	status, health := getHealth(t, h, "/readyz")
+	if status != http.StatusServiceUnavailable || health.Status != "unavailable" || health.Checks["accepting"] != "shutting down" {
+		t.Errorf("readyz: status %d %q with checks %v, want 503 and accepting failed", status, health.Status, health.Checks)
+	}
+
+	// The reaper has stopped, which is expected and not a failure
+	if status, health := getHealth(t, h, "/healthz"); status != http.StatusOK {
+		t.Errorf("healthz: status %d with checks %v, want 200", status, health.Checks)
+	}
+}
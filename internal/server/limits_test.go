@@ -0,0 +1,120 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+	"github.com/gorilla/websocket"
+)
+
+// expectHTTPError fails unless a response carries the given status and code
+func expectHTTPError(t *testing.T, resp *http.Response, wantStatus int, wantCode protocol.ErrorCode) {
+	t.Helper()
+
+	if resp.StatusCode != wantStatus {
+		t.Errorf("status %d, want %d", resp.StatusCode, wantStatus)
+	}
+	var got protocol.ErrorData
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if got.Code != wantCode {
+		t.Errorf("error %s %q, want %s", got.Code, got.Message, wantCode)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxGames   int
+		maxPlayers int
+		path       string
+		body       func(gameID string) interface{}
+	}{
+		{"game cap", 1, 0, "/api/create", func(string) interface{} {
+			return server.CreateGameRequest{PlayerName: "Meera"}
+		}},
+		{"player cap on create", 0, 2, "/api/create", func(string) interface{} {
+			return server.CreateGameRequest{PlayerName: "Meera"}
+		}},
+		{"player cap on join", 0, 2, "/api/join", func(gameID string) interface{} {
+			return server.JoinGameRequest{GameID: gameID, PlayerName: "Meera"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+			cfg.MaxGames, cfg.MaxTotalPlayers = tt.maxGames, tt.maxPlayers
+			h := servertest.NewWithConfig(t, cfg)
+
+			clients := h.NewGame("Asha", "Ravi")
+			resp := h.PostJSON(tt.path, tt.body(clients[0].GameID))
+			expectHTTPError(t, resp, http.StatusServiceUnavailable, protocol.ErrCodeServerFull)
+
+			// Leaving frees the place
+			clients[1].Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+			expectLeft(t, clients[0])
+			clients[0].Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+			expectClosed(t, clients[0])
+			if resp := h.PostJSON("/api/create", server.CreateGameRequest{PlayerName: "Meera"}); resp.StatusCode != http.StatusOK {
+				t.Errorf("create after the game closed: status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestConnectionLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	cfg.MaxConnections = 2
+	h := servertest.NewWithConfig(t, cfg)
+	host := h.NewGame("Asha")[0]
+	h.Connect(host.GameID, host.PlayerID)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(h.WebSocketURL(host.GameID, host.PlayerID), nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("third connection accepted, want it refused")
+	}
+	defer resp.Body.Close()
+	expectHTTPError(t, resp, http.StatusTooManyRequests, protocol.ErrCodeTooManyConnections)
+}
+
+// Idle lobbies are expired and their players told; games in progress have
+// their own, longer timeout
+func TestExpireIdle(t *testing.T) {
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	cfg.LobbyIdleTimeout, cfg.GameIdleTimeout = 300*time.Millisecond, time.Hour
+	cfg.ReapInterval = 20 * time.Millisecond
+	h := servertest.NewWithConfig(t, cfg)
+
+	playing := h.NewGame("Asha", "Ravi")
+	playing[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	playing[0].ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+
+	lobby := h.NewGame("Meera")[0]
+	var expired protocol.ExpiredData
+	if err := json.Unmarshal(lobby.Expect(protocol.TypeExpired).Data, &expired); err != nil {
+		t.Fatalf("decode expired: %v", err)
+	}
+	if expired.GameID != lobby.GameID {
+		t.Errorf("expired names game %s, want %s", expired.GameID, lobby.GameID)
+	}
+	expectClosed(t, lobby)
+	expectGameGone(t, h, lobby.GameID)
+
+	// The game in progress has been idle as long, but is still there
+	if state := playing[1].Refresh(); state.State != models.StatePlaying {
+		t.Errorf("game in progress is %s after the lobby expired, want playing", state.State)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/aiplaybookin/tiffin-go/internal/metrics"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// serverMetrics are the counters and histograms updated while serving
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	messagesIn       *metrics.Counter // By type
+	messagesOut      *metrics.Counter // By type
+	rateLimited      *metrics.Counter
+	dropped          *metrics.Counter
+	broadcastSeconds *metrics.Histogram
+	gameSeconds      *metrics.Histogram
+}
+
+// newServerMetrics registers the metrics updated while serving
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+
+	return &serverMetrics{
+		registry: r,
+
+		messagesIn: r.Counter("tiffin_messages_received_total",
+			"WebSocket messages received, by type.", "type"),
+		messagesOut: r.Counter("tiffin_messages_sent_total",
+			"WebSocket messages queued for clients, by type.", "type"),
+		rateLimited: r.Counter("tiffin_messages_rate_limited_total",
+			"WebSocket messages dropped by the per-IP rate limit."),
+		dropped: r.Counter("tiffin_messages_dropped_total",
+			"Messages dropped because a client's send buffer was full."),
+		broadcastSeconds: r.Histogram("tiffin_broadcast_duration_seconds",
+			"Time to build and queue one broadcast to a game's clients.", metrics.DefaultBuckets),
+		gameSeconds: r.Histogram("tiffin_game_duration_seconds",
+			"Time from start to finish of completed games.",
+			[]float64{60, 180, 300, 600, 900, 1200, 1800, 2700, 3600}),
+	}
+}
+
+// registerGauges adds gauges read from the game manager and hub at scrape
+// time. The state count locks each game in turn, never all games at once.
+func (m *serverMetrics) registerGauges(wh *WSHandler) {
+	gm, hub := wh.gameManager, wh.hub
+	m.registry.GaugeVecFunc("tiffin_games", "Games in memory, by state.", "state", func() map[string]float64 {
+		byState := gm.CountByState()
+
+		counts := map[string]float64{
+			string(models.StateWaiting):  0,
+			string(models.StatePlaying):  0,
+			string(models.StateScoring):  0,
+			string(models.StateFinished): 0,
+		}
+		for state, n := range byState {
+			counts[string(state)] = float64(n)
+		}
+		return counts
+	})
+	m.registry.GaugeFunc("tiffin_players", "Players seated across all games.", func() float64 {
+		_, players := gm.Counts()
+		return float64(players)
+	})
+	m.registry.GaugeFunc("tiffin_connected_clients", "Open WebSocket connections.", func() float64 {
+		return float64(hub.ClientCount())
+	})
+}
+
+// receivedType returns the label for an incoming message type, folding
+// anything outside the protocol into "unknown" to bound label cardinality
+func receivedType(msgType string) string {
+	if _, ok := protocol.ClientMessages[msgType]; ok {
+		return msgType
+	}
+	return "unknown"
+}
+
+// envelopeType reads the type from a marshalled envelope, which always
+// starts with {"type":"...
+func envelopeType(msg []byte) string {
+	const prefix = `{"type":"`
+	if !bytes.HasPrefix(msg, []byte(prefix)) {
+		return "unknown"
+	}
+
+	rest := msg[len(prefix):]
+	if end := bytes.IndexByte(rest, '"'); end >= 0 {
+		return string(rest[:end])
+	}
+	return "unknown"
+}
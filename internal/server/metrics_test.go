@@ -0,0 +1,59 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+)
+
+// The games gauge reads game states, which game actions change under the
+// game's lock; run with -race
+func TestGamesGaugeHoldsGameLock(t *testing.T) {
+	s := NewServer(config.Default(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	created, err := s.createGame("Host", "")
+	if err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+	if _, err := s.joinGame(JoinGameRequest{GameID: created.GameID, PlayerName: "Guest"}); err != nil {
+		t.Fatalf("join game: %v", err)
+	}
+	g, _ := s.gameManager.GetGame(created.GameID)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			g.Lock()
+			g.State = models.StateWaiting
+			game.StartGame(g)
+			g.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			s.hub.metrics.registry.WriteText(io.Discard)
+		}
+	}()
+	wg.Wait()
+
+	var out strings.Builder
+	s.hub.metrics.registry.WriteText(&out)
+	for _, line := range []string{
+		`tiffin_games{state="playing"} 1`,
+		`tiffin_games{state="waiting"} 0`,
+		`tiffin_players 2`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+)
+
+// expectLeft waits for a player_left message and returns it
+func expectLeft(t *testing.T, c *servertest.Client) protocol.PlayerLeftData {
+	t.Helper()
+
+	var left protocol.PlayerLeftData
+	if err := json.Unmarshal(c.Expect(protocol.TypePlayerLeft).Data, &left); err != nil {
+		t.Fatalf("decode player_left: %v", err)
+	}
+	return left
+}
+
+// findPlayer returns a player from a state, or nil if they aren't in it
+func findPlayer(state *protocol.GameState, playerID string) *protocol.PlayerState {
+	for i := range state.Players {
+		if state.Players[i].ID == playerID {
+			return &state.Players[i]
+		}
+	}
+	return nil
+}
+
+// expectGameGone fails unless joining the game says it doesn't exist
+func expectGameGone(t *testing.T, h *servertest.Harness, gameID string) {
+	t.Helper()
+
+	resp := h.PostJSON("/api/join", server.JoinGameRequest{GameID: gameID, PlayerName: "Zoya"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("join after the last player left: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestLeaveBeforeStart(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi", "Meera")
+	asha, ravi, meera := clients[0], clients[1], clients[2]
+
+	ravi.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	left := expectLeft(t, asha)
+	if left.PlayerID != ravi.PlayerID || left.PlayerName != "Ravi" || left.Reason != protocol.LeaveReasonLeft || left.ReplacedByBot || left.NewHostID != "" {
+		t.Errorf("player_left %+v, want Ravi left without a bot or host change", left)
+	}
+	expectClosed(t, ravi)
+	if state := asha.Refresh(); len(state.Players) != 2 || findPlayer(state, ravi.PlayerID) != nil {
+		t.Errorf("%d players after Ravi left, want Asha and Meera", len(state.Players))
+	}
+
+	// The host leaving hands the game to the player still there
+	asha.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	expectLeft(t, meera) // Ravi
+	if left := expectLeft(t, meera); left.PlayerID != asha.PlayerID || left.NewHostID != meera.PlayerID {
+		t.Errorf("player_left %+v, want Asha left and Meera hosts", left)
+	}
+	var changed protocol.HostChangedData
+	if err := json.Unmarshal(meera.Expect(protocol.TypeHostChanged).Data, &changed); err != nil {
+		t.Fatalf("decode host_changed: %v", err)
+	}
+	if changed.HostID != meera.PlayerID || changed.Reason != protocol.HostReasonLeft {
+		t.Errorf("host_changed %+v, want Meera because the host left", changed)
+	}
+	expectClosed(t, asha)
+	if state := meera.Refresh(); len(state.Players) != 1 || state.HostID != meera.PlayerID {
+		t.Errorf("%d players hosted by %s after Asha left, want Meera alone", len(state.Players), state.HostID)
+	}
+
+	// Leaving an empty lobby removes it
+	meera.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	expectClosed(t, meera)
+	expectGameGone(t, h, meera.GameID)
+}
+
+func TestKickPlayer(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi", "Meera")
+	asha, ravi, meera := clients[0], clients[1], clients[2]
+
+	// A guest can't kick, and nobody is removed
+	ravi.Send(protocol.TypeKickPlayer, protocol.KickPlayerData{PlayerID: meera.PlayerID})
+	if got := ravi.ExpectError(); got.Code != protocol.ErrCodeNotHost {
+		t.Errorf("guest kicks: error %s %q, want %s", got.Code, got.Message, protocol.ErrCodeNotHost)
+	}
+	if state := ravi.Refresh(); len(state.Players) != 3 {
+		t.Errorf("%d players after a guest's kick, want 3", len(state.Players))
+	}
+
+	asha.Send(protocol.TypeKickPlayer, protocol.KickPlayerData{PlayerID: asha.PlayerID})
+	if got := asha.ExpectError(); got.Code != protocol.ErrCodeInvalidAction {
+		t.Errorf("host kicks themselves: error %s %q, want %s", got.Code, got.Message, protocol.ErrCodeInvalidAction)
+	}
+
+	asha.Send(protocol.TypeKickPlayer, protocol.KickPlayerData{PlayerID: ravi.PlayerID})
+	if left := expectLeft(t, meera); left.PlayerID != ravi.PlayerID || left.Reason != protocol.LeaveReasonKicked {
+		t.Errorf("player_left %+v, want Ravi kicked", left)
+	}
+	expectClosed(t, ravi)
+	if state := meera.Refresh(); len(state.Players) != 2 || findPlayer(state, ravi.PlayerID) != nil {
+		t.Errorf("%d players after Ravi was kicked, want Asha and Meera", len(state.Players))
+	}
+}
+
+// A player leaving mid-game is replaced by a bot, and the others play on
+// to the end
+func TestBotTakesOver(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi", "Meera")
+	asha, ravi, meera := clients[0], clients[1], clients[2]
+
+	asha.Send(protocol.TypeStartGame, protocol.StartGameData{})
+	for _, c := range clients {
+		c.ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+	}
+
+	ravi.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	expectClosed(t, ravi)
+	humans := []*servertest.Client{asha, meera}
+	states := make([]*protocol.GameState, len(humans))
+	for i, c := range humans {
+		if left := expectLeft(t, c); left.PlayerID != ravi.PlayerID || !left.ReplacedByBot {
+			t.Errorf("player_left %+v, want Ravi replaced by a bot", left)
+		}
+		states[i] = c.Refresh()
+		if p := findPlayer(states[i], ravi.PlayerID); p == nil || !p.IsBot {
+			t.Fatalf("Ravi is %+v after leaving, want a bot in their seat", p)
+		}
+	}
+
+	for states[0].State != models.StateFinished {
+		round, turn := states[0].Round, states[0].Turn
+		for _, c := range humans {
+			c.Send(protocol.TypeSelectCard, protocol.SelectCardData{CardIndex: 0})
+		}
+		for i, c := range humans {
+			states[i] = c.ExpectState(func(s *protocol.GameState) bool {
+				return s.State == models.StateFinished || s.Round != round || s.Turn != turn
+			})
+		}
+	}
+
+	bot := findPlayer(states[0], ravi.PlayerID)
+	if len(states[0].Players) != 3 || bot == nil || !bot.IsBot || len(bot.RoundScores) != 3 {
+		t.Errorf("final state has %d players and Ravi %+v, want 3 with Ravi a bot through every round", len(states[0].Players), bot)
+	}
+}
+
+// The game is removed once the last human leaves, even with bots seated
+func TestGameEndsWithoutHumans(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi")
+	asha, ravi := clients[0], clients[1]
+
+	asha.Send(protocol.TypeStartGame, protocol.StartGameData{})
+	asha.ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+
+	ravi.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	expectLeft(t, asha)
+
+	asha.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	if left := expectLeft(t, asha); left.PlayerID != asha.PlayerID || left.NewHostID != "" {
+		t.Errorf("player_left %+v, want Asha left with no new host", left)
+	}
+	expectClosed(t, asha)
+	expectGameGone(t, h, asha.GameID)
+}
+
+// expectHostChanged waits for a host_changed message and returns it
+func expectHostChanged(t *testing.T, c *servertest.Client) protocol.HostChangedData {
+	t.Helper()
+
+	var changed protocol.HostChangedData
+	if err := json.Unmarshal(c.Expect(protocol.TypeHostChanged).Data, &changed); err != nil {
+		t.Fatalf("decode host_changed: %v", err)
+	}
+	return changed
+}
+
+func TestTransferHost(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi", "Meera")
+	asha, ravi, meera := clients[0], clients[1], clients[2]
+
+	tests := []struct {
+		name     string
+		sender   *servertest.Client
+		target   string
+		wantCode protocol.ErrorCode
+	}{
+		{"by guest", ravi, ravi.PlayerID, protocol.ErrCodeNotHost},
+		{"to stranger", asha, "nobody", protocol.ErrCodePlayerNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sender.Send(protocol.TypeTransferHost, protocol.TransferHostData{PlayerID: tt.target})
+			if got := tt.sender.ExpectError(); got.Code != tt.wantCode {
+				t.Errorf("error %s %q, want %s", got.Code, got.Message, tt.wantCode)
+			}
+		})
+	}
+
+	asha.Send(protocol.TypeTransferHost, protocol.TransferHostData{PlayerID: ravi.PlayerID})
+	for _, c := range clients {
+		changed := expectHostChanged(t, c)
+		if changed.HostID != ravi.PlayerID || changed.HostName != "Ravi" || changed.Reason != protocol.HostReasonTransferred {
+			t.Errorf("%s got host_changed %+v, want Ravi by transfer", c.PlayerID, changed)
+		}
+	}
+	if state := meera.Refresh(); state.HostID != ravi.PlayerID {
+		t.Errorf("state has host %s, want Ravi", state.HostID)
+	}
+
+	// Only the new host can start
+	asha.Send(protocol.TypeStartGame, protocol.StartGameData{})
+	if got := asha.ExpectError(); got.Code != protocol.ErrCodeNotHost {
+		t.Errorf("old host starts: error %s %q, want %s", got.Code, got.Message, protocol.ErrCodeNotHost)
+	}
+	ravi.Send(protocol.TypeStartGame, protocol.StartGameData{})
+	if state := asha.Refresh(); state.State != models.StatePlaying {
+		asha.ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+	}
+
+	// A bot can't host
+	meera.Send(protocol.TypeLeaveGame, protocol.LeaveGameData{})
+	expectLeft(t, ravi)
+	ravi.Send(protocol.TypeTransferHost, protocol.TransferHostData{PlayerID: meera.PlayerID})
+	if got := ravi.ExpectError(); got.Code != protocol.ErrCodePlayerNotFound {
+		t.Errorf("transfer to a bot: error %s %q, want %s", got.Code, got.Message, protocol.ErrCodePlayerNotFound)
+	}
+}
+
+// The host's last connection closing hands the game to a connected player
+func TestHostDisconnects(t *testing.T) {
+	h := servertest.New(t)
+	clients := h.NewGame("Asha", "Ravi")
+	asha, ravi := clients[0], clients[1]
+
+	asha.Conn.Close()
+	changed := expectHostChanged(t, ravi)
+	if changed.HostID != ravi.PlayerID || changed.Reason != protocol.HostReasonDisconnected {
+		t.Errorf("host_changed %+v, want Ravi because the host disconnected", changed)
+	}
+
+	// Asha comes back as a guest
+	asha = h.Connect(asha.GameID, asha.PlayerID)
+	if state := asha.Refresh(); state.HostID != ravi.PlayerID {
+		t.Errorf("state has host %s after Asha reconnected, want Ravi", state.HostID)
+	}
+}
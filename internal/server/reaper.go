@@ -0,0 +1,45 @@
+package server
+
+import (
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// runReaper periodically expires idle games and prunes rate limiters until shutdown
+func (s *Server) runReaper() {
+	ticker := time.NewTicker(s.reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.reapIdleGames(now)
+			s.pruneLimiters()
+			s.lastReap.Store(now.UnixNano())
+		case <-s.stopReaper:
+			return
+		}
+	}
+}
+
+// reapIdleGames removes idle games and disconnects anyone still in them
+func (s *Server) reapIdleGames(now time.Time) {
+	expired := s.gameManager.ExpireIdle(now)
+
+	for _, gameID := range expired {
+		s.hub.BroadcastToGame(gameID, protocol.TypeExpired, protocol.ExpiredData{
+			GameID:  gameID,
+			Message: "game closed after inactivity",
+		})
+		s.hub.CloseGame(gameID)
+	}
+}
+
+// pruneLimiters forgets rate limit buckets that have refilled
+func (s *Server) pruneLimiters() {
+	s.createLimiter.Prune()
+	s.joinLimiter.Prune()
+	s.wsHandler.limiter.Prune()
+	s.wsHandler.chatLimiter.Prune()
+}
@@ -0,0 +1,395 @@
+// Package servertest provides an in-process harness for exercising the game
+// server over real HTTP and WebSocket connections.
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiplaybookin/tiffin-go/internal/backplane"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/jsonpatch"
+	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTimeout is how long a client waits for a message before failing
+const DefaultTimeout = 5 * time.Second
+
+// Harness runs a game server on a local httptest listener
+type Harness struct {
+	t      testing.TB
+	Server *server.Server
+	HTTP   *httptest.Server
+}
+
+// New starts a server with the default config and registers its shutdown
+// with t.Cleanup. Rate limits are off because every test client shares the
+// loopback address.
+func New(t testing.TB) *Harness {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+	return NewWithConfig(t, cfg)
+}
+
+// NewWithConfig starts a server with the given config
+func NewWithConfig(t testing.TB, cfg *config.Config) *Harness {
+	t.Helper()
+
+	return newHarness(t, cfg, nil)
+}
+
+// NewCluster starts n servers sharing an in-process backplane. A game
+// created on one can be joined and played through any of them.
+func NewCluster(t testing.TB, n int) []*Harness {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.CreateRate, cfg.JoinRate, cfg.MessageRate = 0, 0, 0
+
+	bp := backplane.NewMemory()
+	harnesses := make([]*Harness, n)
+	for i := range harnesses {
+		harnesses[i] = newHarness(t, cfg, bp)
+	}
+	return harnesses
+}
+
+// NewWithBackplane starts a server joined to bp, such as a
+// backplane.Remote, so several harnesses can share games over it
+func NewWithBackplane(t testing.TB, cfg *config.Config, bp backplane.Backplane) *Harness {
+	t.Helper()
+
+	return newHarness(t, cfg, bp)
+}
+
+// newHarness starts a server, joined to bp unless it is nil. Cleanup shuts
+// the server down, stopping its reaper, before closing the listener.
+func newHarness(t testing.TB, cfg *config.Config, bp backplane.Backplane) *Harness {
+	t.Helper()
+
+	srv := server.NewServer(cfg, slog.Default())
+	if bp != nil {
+		if err := srv.UseBackplane(bp); err != nil {
+			t.Fatalf("use backplane: %v", err)
+		}
+	}
+	srv.Start()
+
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+
+	h := &Harness{
+		t:      t,
+		Server: srv,
+		HTTP:   httptest.NewServer(mux),
+	}
+	t.Cleanup(h.HTTP.Close)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("shut down server: %v", err)
+		}
+	})
+
+	return h
+}
+
+// PostJSON sends a JSON body to an API path and returns the raw response
+func (h *Harness) PostJSON(path string, body interface{}) *http.Response {
+	h.t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatalf("marshal %s body: %v", path, err)
+	}
+
+	resp, err := h.HTTP.Client().Post(h.HTTP.URL+path, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		h.t.Fatalf("POST %s: %v", path, err)
+	}
+	h.t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+// CreateGame creates a game through /api/create
+func (h *Harness) CreateGame(playerName string) server.CreateGameResponse {
+	h.t.Helper()
+
+	resp := h.PostJSON("/api/create", server.CreateGameRequest{PlayerName: playerName})
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("create game: status %d", resp.StatusCode)
+	}
+
+	var created server.CreateGameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		h.t.Fatalf("decode create response: %v", err)
+	}
+	return created
+}
+
+// JoinGame joins a game through /api/join
+func (h *Harness) JoinGame(gameID, playerName string) server.JoinGameResponse {
+	h.t.Helper()
+
+	resp := h.PostJSON("/api/join", server.JoinGameRequest{GameID: gameID, PlayerName: playerName})
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("join game %s: status %d", gameID, resp.StatusCode)
+	}
+
+	var joined server.JoinGameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&joined); err != nil {
+		h.t.Fatalf("decode join response: %v", err)
+	}
+	return joined
+}
+
+// WebSocketURL returns the URL a player connects to
+func (h *Harness) WebSocketURL(gameID, playerID string) string {
+	return "ws" + strings.TrimPrefix(h.HTTP.URL, "http") + "/ws?game_id=" + gameID + "&player_id=" + playerID
+}
+
+// Dial opens a WebSocket for a player without saying hello, so a test can
+// read what the server sends first
+func (h *Harness) Dial(gameID, playerID string) *Client {
+	h.t.Helper()
+
+	wsURL := h.WebSocketURL(gameID, playerID)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		h.t.Fatalf("dial %s: %v", wsURL, err)
+	}
+	h.t.Cleanup(func() { conn.Close() })
+
+	return &Client{
+		t:        h.t,
+		Conn:     conn,
+		GameID:   gameID,
+		PlayerID: playerID,
+		Timeout:  DefaultTimeout,
+	}
+}
+
+// Connect opens a WebSocket for a player and waits for their first game state
+func (h *Harness) Connect(gameID, playerID string) *Client {
+	h.t.Helper()
+
+	c := h.Dial(gameID, playerID)
+	c.Send(protocol.TypeHello, protocol.HelloData{ProtocolVersion: protocol.Version})
+	c.Expect(protocol.TypeWelcome)
+
+	// The initial state arrives before the welcome and is skipped, so ask explicitly
+	c.Send(protocol.TypeGetState, protocol.GetStateData{})
+	c.ExpectState(func(*protocol.GameState) bool { return true })
+
+	return c
+}
+
+// NewGame creates a game hosted by the first name, joins the rest and
+// connects every player. The host is always the first client.
+func (h *Harness) NewGame(names ...string) []*Client {
+	h.t.Helper()
+
+	if len(names) == 0 {
+		h.t.Fatalf("NewGame needs at least one player")
+	}
+
+	created := h.CreateGame(names[0])
+	playerIDs := []string{created.PlayerID}
+	for _, name := range names[1:] {
+		playerIDs = append(playerIDs, h.JoinGame(created.GameID, name).PlayerID)
+	}
+
+	clients := make([]*Client, 0, len(playerIDs))
+	for _, playerID := range playerIDs {
+		clients = append(clients, h.Connect(created.GameID, playerID))
+	}
+	return clients
+}
+
+// PlayGame starts the game as the host and has every player pick their
+// first card each turn until the game finishes. It returns the final state
+// as seen by each client.
+func PlayGame(clients []*Client) []*protocol.GameState {
+	if len(clients) == 0 {
+		return nil
+	}
+	clients[0].t.Helper()
+
+	clients[0].Send(protocol.TypeStartGame, protocol.StartGameData{})
+	states := make([]*protocol.GameState, len(clients))
+	for i, c := range clients {
+		states[i] = c.ExpectState(func(s *protocol.GameState) bool { return s.State == models.StatePlaying })
+	}
+
+	for states[0].State != models.StateFinished {
+		round, turn := states[0].Round, states[0].Turn
+		for _, c := range clients {
+			c.Send(protocol.TypeSelectCard, protocol.SelectCardData{CardIndex: 0})
+		}
+		for i, c := range clients {
+			states[i] = c.ExpectState(func(s *protocol.GameState) bool {
+				return s.State == models.StateFinished || s.Round != round || s.Turn != turn
+			})
+		}
+	}
+
+	return states
+}
+
+// Client is a scripted WebSocket player
+type Client struct {
+	t        testing.TB
+	Conn     *websocket.Conn
+	GameID   string
+	PlayerID string
+	Timeout  time.Duration
+
+	seq   uint64      // Last state message applied
+	state interface{} // Current state as a generic JSON value
+}
+
+// Send writes a typed message to the server
+func (c *Client) Send(messageType string, data interface{}) {
+	c.t.Helper()
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		c.t.Fatalf("marshal %s data: %v", messageType, err)
+	}
+
+	msg := protocol.Message{Type: messageType, Data: payload}
+	if err := c.Conn.WriteJSON(msg); err != nil {
+		c.t.Fatalf("send %s: %v", messageType, err)
+	}
+}
+
+// Next reads the next message from the server
+func (c *Client) Next() protocol.Message {
+	c.t.Helper()
+
+	c.Conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	var msg protocol.Message
+	if err := c.Conn.ReadJSON(&msg); err != nil {
+		c.t.Fatalf("player %s: read message: %v", c.PlayerID, err)
+	}
+	return msg
+}
+
+// Expect skips messages until one of the given type arrives
+func (c *Client) Expect(messageType string) protocol.Message {
+	c.t.Helper()
+
+	for {
+		msg := c.Next()
+		if msg.Type == messageType {
+			return msg
+		}
+	}
+}
+
+// ExpectError waits for an error message and returns it
+func (c *Client) ExpectError() protocol.ErrorData {
+	c.t.Helper()
+
+	var data protocol.ErrorData
+	c.decode(c.Expect(protocol.TypeError), &data)
+	return data
+}
+
+// Refresh asks for a snapshot and returns it, dropping any state messages
+// queued before it. Use it once Expect has skipped patches, which would
+// otherwise leave a gap for ExpectState.
+func (c *Client) Refresh() *protocol.GameState {
+	c.t.Helper()
+
+	c.Send(protocol.TypeGetState, protocol.GetStateData{})
+	for {
+		msg := c.Next()
+		if msg.Type == protocol.TypeStateSnapshot {
+			c.applySnapshot(msg)
+			return c.currentState()
+		}
+	}
+}
+
+// ExpectState applies snapshots and patches until the game state matches
+// the predicate. A gap in sequence numbers fails the test.
+func (c *Client) ExpectState(match func(*protocol.GameState) bool) *protocol.GameState {
+	c.t.Helper()
+
+	for {
+		msg := c.Next()
+		switch msg.Type {
+		case protocol.TypeStateSnapshot:
+			c.applySnapshot(msg)
+
+		case protocol.TypeStatePatch:
+			var patch protocol.StatePatchData
+			c.decode(msg, &patch)
+			if c.state == nil || patch.Seq != c.seq+1 {
+				c.t.Fatalf("player %s: patch %d does not follow state %d", c.PlayerID, patch.Seq, c.seq)
+			}
+			state, err := jsonpatch.Apply(c.state, patch.Ops)
+			if err != nil {
+				c.t.Fatalf("player %s: apply patch %d: %v", c.PlayerID, patch.Seq, err)
+			}
+			c.seq, c.state = patch.Seq, state
+
+		default:
+			continue
+		}
+
+		if state := c.currentState(); match(state) {
+			return state
+		}
+	}
+}
+
+// applySnapshot replaces the client's state with a snapshot's
+func (c *Client) applySnapshot(msg protocol.Message) {
+	c.t.Helper()
+
+	var snapshot struct {
+		Seq   uint64      `json:"seq"`
+		State interface{} `json:"state"`
+	}
+	c.decode(msg, &snapshot)
+	c.seq, c.state = snapshot.Seq, snapshot.State
+}
+
+// currentState decodes the client's generic state into a GameState
+func (c *Client) currentState() *protocol.GameState {
+	c.t.Helper()
+
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		c.t.Fatalf("player %s: encode state: %v", c.PlayerID, err)
+	}
+	var state protocol.GameState
+	if err := json.Unmarshal(data, &state); err != nil {
+		c.t.Fatalf("player %s: decode state: %v", c.PlayerID, err)
+	}
+	return &state
+}
+
+func (c *Client) decode(msg protocol.Message, v interface{}) {
+	c.t.Helper()
+
+	if err := json.Unmarshal(msg.Data, v); err != nil {
+		c.t.Fatalf("decode %s data: %v", msg.Type, err)
+	}
+}
@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/server"
+	"github.com/aiplaybookin/tiffin-go/internal/server/servertest"
+	"github.com/gorilla/websocket"
+)
+
+// shutdown starts a graceful shutdown and waits for the connections to drain
+func shutdown(t *testing.T, h *servertest.Harness) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), servertest.DefaultTimeout)
+	defer cancel()
+	if err := h.Server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+// Shutting down warns every player before closing their connection, then
+// turns away new games and connections
+func TestShutdownBroadcast(t *testing.T) {
+	h := servertest.New(t)
+	clients := append(h.NewGame("Asha", "Ravi"), h.NewGame("Meera")...)
+
+	shutdown(t, h)
+
+	for _, c := range clients {
+		var notice protocol.ServerShuttingDownData
+		if err := json.Unmarshal(c.Expect(protocol.TypeServerShuttingDown).Data, &notice); err != nil {
+			t.Fatalf("decode server_shutting_down: %v", err)
+		}
+		if notice.Message != "server is shutting down" {
+			t.Errorf("%s warned %q, want %q", c.PlayerID, notice.Message, "server is shutting down")
+		}
+		expectClosed(t, c)
+	}
+
+	resp := h.PostJSON("/api/create", server.CreateGameRequest{PlayerName: "Zoya"})
+	expectHTTPError(t, resp, http.StatusServiceUnavailable, protocol.ErrCodeShuttingDown)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(h.WebSocketURL(clients[0].GameID, clients[0].PlayerID), nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("connection accepted during shutdown")
+	}
+	defer resp.Body.Close()
+	expectHTTPError(t, resp, http.StatusServiceUnavailable, protocol.ErrCodeShuttingDown)
+}
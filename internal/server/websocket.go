@@ -2,134 +2,503 @@ package server
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for now
-	},
+// sendBufferSize is how many messages may queue for a client before it is
+// considered too slow and disconnected
+const sendBufferSize = 256
+
+// newUpgrader creates an upgrader that accepts the given origins (all if empty)
+func newUpgrader(allowedOrigins []string) websocket.Upgrader {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			if len(allowed) == 0 {
+				return true
+			}
+			return allowed[strings.ToLower(r.Header.Get("Origin"))]
+		},
+	}
+}
+
+// writeTimeout bounds how long a single write to a client may take
+const writeTimeout = 10 * time.Second
+
+// connLimits bounds the lifecycle of a WebSocket connection
+type connLimits struct {
+	pingInterval   time.Duration // How often the server pings
+	pongTimeout    time.Duration // Connection is dropped if nothing is heard for this long
+	maxMessageSize int64         // Largest message accepted from the client, in bytes
 }
 
-// Client represents a WebSocket client connection
+// Client represents one WebSocket connection. A player may have several
+// (tabs or devices); each receives the player's private state, and any of
+// them may act for the player.
 type Client struct {
 	ID     string
 	GameID string
-	Conn   *websocket.Conn
-	Send   chan []byte
+	Conn   *websocket.Conn // nil for connections relayed from another instance
+	Send   chan []byte     // Closed by the hub when the client leaves its room
+	IP     string          // Address messages are rate limited by
+
+	log         *slog.Logger // Carries game_id and player_id
+	limits      connLimits
+	connectedAt time.Time
+	remoteAddr  string
+
+	// State sync, guarded by the client's room lock
+	seq       uint64      // Sequence number of the last state message sent
+	lastState interface{} // That state as a generic JSON value
+
+	// Health counters, updated by the pumps
+	messagesIn  atomic.Int64
+	messagesOut atomic.Int64
+	bytesIn     atomic.Int64
+	bytesOut    atomic.Int64
+	pings       atomic.Int64
+	pongs       atomic.Int64
+	lastSeen    atomic.Int64 // Unix nanoseconds of the last message or pong
+	lastPing    atomic.Int64 // Unix nanoseconds the last ping was sent
+	rtt         atomic.Int64 // Last measured ping round trip
 }
 
-// Hub maintains active clients and broadcasts messages
-type Hub struct {
-	clients    map[string]*Client // ClientID -> Client
-	broadcast  chan *BroadcastMsg
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+// ClientStats is a snapshot of one connection's health
+type ClientStats struct {
+	PlayerID    string        `json:"player_id"`
+	GameID      string        `json:"game_id"`
+	RemoteAddr  string        `json:"remote_addr"`
+	ConnectedAt time.Time     `json:"connected_at"`
+	LastSeen    time.Time     `json:"last_seen"`
+	MessagesIn  int64         `json:"messages_in"`
+	MessagesOut int64         `json:"messages_out"`
+	BytesIn     int64         `json:"bytes_in"`
+	BytesOut    int64         `json:"bytes_out"`
+	Pings       int64         `json:"pings"`
+	Pongs       int64         `json:"pongs"`
+	RTT         time.Duration `json:"rtt_ns"` // Zero until the first pong
+	Queued      int           `json:"queued"` // Messages waiting in the send buffer
+}
+
+// NewClient creates a client for a player's connection
+func NewClient(playerID, gameID string, conn *websocket.Conn, limits connLimits, logger *slog.Logger) *Client {
+	now := time.Now()
+	c := &Client{
+		ID:          playerID,
+		GameID:      gameID,
+		Conn:        conn,
+		Send:        make(chan []byte, sendBufferSize),
+		log:         logger.With("game_id", gameID, "player_id", playerID),
+		limits:      limits,
+		connectedAt: now,
+	}
+	if conn != nil {
+		c.remoteAddr = conn.RemoteAddr().String()
+	}
+	c.lastSeen.Store(now.UnixNano())
+	return c
 }
 
-// BroadcastMsg represents a message to broadcast
-type BroadcastMsg struct {
-	GameID  string
-	Message []byte
+// Stats returns a snapshot of the client's health counters
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		PlayerID:    c.ID,
+		GameID:      c.GameID,
+		RemoteAddr:  c.remoteAddr,
+		ConnectedAt: c.connectedAt,
+		LastSeen:    time.Unix(0, c.lastSeen.Load()),
+		MessagesIn:  c.messagesIn.Load(),
+		MessagesOut: c.messagesOut.Load(),
+		BytesIn:     c.bytesIn.Load(),
+		BytesOut:    c.bytesOut.Load(),
+		Pings:       c.pings.Load(),
+		Pongs:       c.pongs.Load(),
+		RTT:         time.Duration(c.rtt.Load()),
+		Queued:      len(c.Send),
+	}
+}
+
+// seen records activity from the client and extends the read deadline
+func (c *Client) seen() {
+	now := time.Now()
+	c.lastSeen.Store(now.UnixNano())
+	c.Conn.SetReadDeadline(now.Add(c.limits.pongTimeout))
+}
+
+// room holds the clients connected to one game
+type room struct {
+	clients map[*Client]bool
+	metrics *serverMetrics
+	mu      sync.Mutex
+}
+
+// Hub maintains active clients grouped into one room per game.
+//
+// Lock order is Hub.mu, then room.mu. A client's Send channel is only written
+// or closed while holding its room's lock and after checking the client is
+// still in the room, so a send can never hit a closed channel.
+type Hub struct {
+	rooms   map[string]*room // GameID -> room
+	metrics *serverMetrics
+	log     *slog.Logger
+	mu      sync.RWMutex
 }
 
-// NewHub creates a new Hub
-func NewHub() *Hub {
+// NewHub creates a new Hub that records traffic in m
+func NewHub(m *serverMetrics, logger *slog.Logger) *Hub {
 	return &Hub{
-		clients:    make(map[string]*Client),
-		broadcast:  make(chan *BroadcastMsg),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		rooms:   make(map[string]*room),
+		metrics: m,
+		log:     logger,
 	}
 }
 
-// Run starts the hub
-func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client.ID] = client
-			h.mu.Unlock()
-
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client.ID]; ok {
-				delete(h.clients, client.ID)
-				close(client.Send)
-			}
-			h.mu.Unlock()
-
-		case msg := <-h.broadcast:
-			h.mu.RLock()
-			for _, client := range h.clients {
-				if client.GameID == msg.GameID {
-					select {
-					case client.Send <- msg.Message:
-					default:
-						close(client.Send)
-						delete(h.clients, client.ID)
-					}
-				}
-			}
-			h.mu.RUnlock()
-		}
+// Register adds a client to its game's room
+func (h *Hub) Register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, exists := h.rooms[c.GameID]
+	if !exists {
+		r = &room{clients: make(map[*Client]bool), metrics: h.metrics}
+		h.rooms[c.GameID] = r
+		h.log.Debug("room opened", "game_id", c.GameID)
+	}
+
+	r.mu.Lock()
+	r.clients[c] = true
+	r.mu.Unlock()
+}
+
+// Unregister removes a client from its room and closes its Send channel
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, exists := h.rooms[c.GameID]
+	if !exists {
+		return
+	}
+
+	r.mu.Lock()
+	r.remove(c)
+	empty := len(r.clients) == 0
+	r.mu.Unlock()
+
+	if empty {
+		delete(h.rooms, c.GameID)
+		h.log.Debug("room closed", "game_id", c.GameID)
 	}
 }
 
 // BroadcastToGame sends a message to all clients in a game
 func (h *Hub) BroadcastToGame(gameID string, messageType string, data interface{}) {
-	message := map[string]interface{}{
-		"type": messageType,
-		"data": data,
+	jsonMsg, err := marshalMessage(messageType, data)
+	if err != nil {
+		return
+	}
+
+	h.BroadcastEach(gameID, func(*Client) []byte { return jsonMsg })
+}
+
+// BroadcastEach sends every client in a game the message built for it,
+// skipping clients whose message is nil
+func (h *Hub) BroadcastEach(gameID string, build func(*Client) []byte) {
+	r := h.room(gameID)
+	if r == nil {
+		return
+	}
+
+	start := time.Now()
+	r.mu.Lock()
+	for c := range r.clients {
+		if msg := build(c); msg != nil {
+			r.send(c, msg)
+		}
+	}
+	empty := len(r.clients) == 0
+	r.mu.Unlock()
+	h.metrics.broadcastSeconds.Observe(time.Since(start).Seconds())
+
+	if empty {
+		h.pruneRoom(gameID, r)
+	}
+}
+
+// BroadcastAll sends a message to every connected client
+func (h *Hub) BroadcastAll(messageType string, data interface{}) {
+	jsonMsg, err := marshalMessage(messageType, data)
+	if err != nil {
+		return
 	}
 
-	jsonMsg, err := json.Marshal(message)
+	for _, gameID := range h.gameIDs() {
+		h.BroadcastEach(gameID, func(*Client) []byte { return jsonMsg })
+	}
+}
+
+// Send sends a message to a single client if it is still connected
+func (h *Hub) Send(c *Client, messageType string, data interface{}) {
+	jsonMsg, err := marshalMessage(messageType, data)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
 		return
 	}
 
-	h.broadcast <- &BroadcastMsg{
-		GameID:  gameID,
-		Message: jsonMsg,
+	h.BroadcastEach(c.GameID, func(other *Client) []byte {
+		if other != c {
+			return nil
+		}
+		return jsonMsg
+	})
+}
+
+// CloseAll disconnects every client once their pending messages are written
+func (h *Hub) CloseAll() {
+	for _, gameID := range h.gameIDs() {
+		h.CloseGame(gameID)
+	}
+}
+
+// CloseGame disconnects every client in a game once their pending messages are written
+func (h *Hub) CloseGame(gameID string) {
+	h.closeWhere(gameID, func(*Client) bool { return true })
+}
+
+// ClosePlayer disconnects a player once their pending messages are written
+func (h *Hub) ClosePlayer(gameID, playerID string) {
+	h.closeWhere(gameID, func(c *Client) bool { return c.ID == playerID })
+}
+
+// PlayerConnections returns how many connections a player has open
+func (h *Hub) PlayerConnections(gameID, playerID string) int {
+	r := h.room(gameID)
+	if r == nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for c := range r.clients {
+		if c.ID == playerID {
+			count++
+		}
+	}
+	return count
+}
+
+// Stats returns a health snapshot of every connected client
+func (h *Hub) Stats() []ClientStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var stats []ClientStats
+	for _, r := range h.rooms {
+		r.mu.Lock()
+		for c := range r.clients {
+			stats = append(stats, c.Stats())
+		}
+		r.mu.Unlock()
+	}
+	return stats
+}
+
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, r := range h.rooms {
+		r.mu.Lock()
+		count += len(r.clients)
+		r.mu.Unlock()
+	}
+	return count
+}
+
+// closeWhere removes and closes the clients in a game that match
+func (h *Hub) closeWhere(gameID string, match func(*Client) bool) {
+	r := h.room(gameID)
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	for c := range r.clients {
+		if match(c) {
+			r.remove(c)
+		}
+	}
+	empty := len(r.clients) == 0
+	r.mu.Unlock()
+
+	if empty {
+		h.pruneRoom(gameID, r)
+	}
+}
+
+// room returns a game's room, or nil if nobody is connected
+func (h *Hub) room(gameID string) *room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.rooms[gameID]
+}
+
+// gameIDs returns the games with connected clients
+func (h *Hub) gameIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.rooms))
+	for id := range h.rooms {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// pruneRoom deletes a room if it is still registered and empty
+func (h *Hub) pruneRoom(gameID string, r *room) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if h.rooms[gameID] == r && len(r.clients) == 0 {
+		delete(h.rooms, gameID)
+		h.log.Debug("room closed", "game_id", gameID)
+	}
+}
+
+// send queues a message, dropping the client if its buffer is full; caller holds r.mu
+func (r *room) send(c *Client, msg []byte) {
+	select {
+	case c.Send <- msg:
+		r.metrics.messagesOut.Inc(envelopeType(msg))
+	default:
+		c.log.Warn("send buffer full, disconnecting", "queued", len(c.Send))
+		r.metrics.dropped.Inc()
+		r.remove(c)
+	}
+}
+
+// remove takes a client out of the room and closes its Send channel; caller holds r.mu
+func (r *room) remove(c *Client) {
+	if r.clients[c] {
+		delete(r.clients, c)
+		close(c.Send)
+	}
+}
+
+// marshalMessage encodes a message envelope
+func marshalMessage(messageType string, data interface{}) ([]byte, error) {
+	jsonMsg, err := json.Marshal(protocol.Envelope{Type: messageType, Data: data})
+	if err != nil {
+		slog.Error("marshaling message failed", "type", messageType, "err", err)
 	}
+	return jsonMsg, err
+}
+
+// messageHandler acts on a connection's messages: WSHandler for games this
+// instance runs, or a relay to the instance that owns the game
+type messageHandler interface {
+	HandleMessage(c *Client, message []byte)
+	HandleDisconnect(c *Client)
 }
 
-// readPump pumps messages from the websocket connection to the hub
-func (c *Client) readPump(h *Hub, handler *WSHandler) {
+// readPump pumps messages from the websocket connection to the hub. The
+// connection is dropped if a message exceeds the size limit or nothing,
+// not even a pong, arrives within the pong timeout.
+func (c *Client) readPump(h *Hub, handler messageHandler) {
 	defer func() {
-		h.unregister <- c
+		h.Unregister(c)
 		c.Conn.Close()
+		handler.HandleDisconnect(c)
+		stats := c.Stats()
+		c.log.Info("client disconnected",
+			"remote_addr", stats.RemoteAddr,
+			"duration", time.Since(stats.ConnectedAt).Round(time.Second),
+			"messages_in", stats.MessagesIn,
+			"messages_out", stats.MessagesOut,
+			"rtt", stats.RTT)
 	}()
 
+	c.Conn.SetReadLimit(c.limits.maxMessageSize)
+	c.seen()
+	c.Conn.SetPongHandler(func(string) error {
+		c.pongs.Add(1)
+		if sent := c.lastPing.Load(); sent != 0 {
+			c.rtt.Store(time.Now().UnixNano() - sent)
+		}
+		c.seen()
+		return nil
+	})
+
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				c.log.Warn("connection closed unexpectedly", "err", err)
 			}
 			break
 		}
+		c.messagesIn.Add(1)
+		c.bytesIn.Add(int64(len(message)))
+		c.seen()
 
 		// Handle incoming message
 		handler.HandleMessage(c, message)
 	}
 }
 
-// writePump pumps messages from the hub to the websocket connection
+// writePump pumps messages from the hub to the websocket connection and
+// pings the client so dead connections are noticed
 func (c *Client) writePump() {
-	defer c.Conn.Close()
+	ticker := time.NewTicker(c.limits.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
 
-	for message := range c.Send {
-		err := c.Conn.WriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			return
+	for {
+		select {
+		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if !ok {
+				// The hub closed the channel, so say goodbye properly
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+			c.messagesOut.Add(1)
+			c.bytesOut.Add(int64(len(message)))
+
+		case <-ticker.C:
+			now := time.Now()
+			c.Conn.SetWriteDeadline(now.Add(writeTimeout))
+			c.lastPing.Store(now.UnixNano())
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			c.pings.Add(1)
 		}
 	}
 }
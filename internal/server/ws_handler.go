@@ -2,123 +2,550 @@ package server
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
 
+	"github.com/aiplaybookin/tiffin-go/internal/chat"
+	"github.com/aiplaybookin/tiffin-go/internal/config"
 	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/jsonpatch"
 	"github.com/aiplaybookin/tiffin-go/internal/models"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+	"github.com/aiplaybookin/tiffin-go/internal/ratelimit"
 )
 
 // WSHandler handles WebSocket messages
 type WSHandler struct {
 	hub         *Hub
 	gameManager *GameManager
+	turnTimeout time.Duration      // 0 disables the turn timer
+	limiter     *ratelimit.Limiter // Messages per IP; nil when unlimited
+	log         *slog.Logger
+
+	chatMaxLength int
+	chatHistory   int
+	chatLimiter   *ratelimit.Limiter          // Chat and reactions per player
+	chatFilter    atomic.Pointer[chat.Filter] // Holds nil to relay chat unchanged
 }
 
 // NewWSHandler creates a new WebSocket handler
-func NewWSHandler(hub *Hub, gm *GameManager) *WSHandler {
-	return &WSHandler{
+func NewWSHandler(hub *Hub, gm *GameManager, cfg *config.Config, logger *slog.Logger) *WSHandler {
+	wh := &WSHandler{
 		hub:         hub,
 		gameManager: gm,
+		turnTimeout: cfg.TurnTimeout,
+		limiter:     ratelimit.New(float64(cfg.MessageRate), cfg.MessageRate),
+		log:         logger,
+
+		chatMaxLength: cfg.ChatMaxLength,
+		chatHistory:   cfg.ChatHistory,
+		chatLimiter:   ratelimit.PerMinute(cfg.ChatRate),
 	}
+	wh.SetChatFilter(chat.MaskWords(cfg.ChatBlocklist))
+	return wh
 }
 
-// WSMessage represents an incoming WebSocket message
-type WSMessage struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
+// SetChatFilter replaces the hook that cleans or rejects chat text
+func (wh *WSHandler) SetChatFilter(filter chat.Filter) {
+	wh.chatFilter.Store(&filter)
 }
 
-// SelectCardData represents data for selecting a card
-type SelectCardData struct {
-	CardIndex int `json:"card_index"`
+// HandleConnect marks a new connection's player as connected and sends the
+// room the updated state and the client the recent chat
+func (wh *WSHandler) HandleConnect(client *Client) {
+	defer wh.gameManager.LockGame(client.GameID)()
+
+	wh.gameManager.MarkConnected(client.GameID, client.ID)
+	wh.gameManager.Touch(client.GameID)
+	wh.broadcastGameState(client.GameID)
+
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil || len(g.Chat) == 0 {
+		return
+	}
+
+	messages := make([]protocol.ChatBroadcastData, 0, len(g.Chat))
+	for _, msg := range g.Chat {
+		messages = append(messages, chatData(msg))
+	}
+	wh.sendToClient(client, protocol.TypeChatHistory, protocol.ChatHistoryData{Messages: messages})
 }
 
 // HandleMessage processes incoming WebSocket messages
 func (wh *WSHandler) HandleMessage(client *Client, message []byte) {
-	var msg WSMessage
+	if ok, retryAfter := wh.limiter.Allow(client.IP); !ok {
+		wh.hub.metrics.rateLimited.Inc()
+		client.log.Debug("message rate limited", "ip", client.IP)
+		wh.sendError(client, protocol.ErrCodeRateLimited, fmt.Sprintf("too many messages, retry in %s", retryAfter.Round(time.Millisecond)))
+		return
+	}
+
+	// Only this game waits; other games' messages are handled in parallel
+	defer wh.gameManager.LockGame(client.GameID)()
+
+	wh.gameManager.Touch(client.GameID)
+
+	var msg protocol.Message
 	if err := json.Unmarshal(message, &msg); err != nil {
-		log.Printf("Error unmarshaling message: %v", err)
+		wh.hub.metrics.messagesIn.Inc("invalid")
+		client.log.Warn("invalid message", "err", err)
+		wh.sendError(client, protocol.ErrCodeBadMessage, "message is not valid JSON")
 		return
 	}
+	wh.hub.metrics.messagesIn.Inc(receivedType(msg.Type))
+	client.log.Debug("message received", "type", msg.Type, "bytes", len(message))
 
 	switch msg.Type {
-	case "select_card":
+	case protocol.TypeHello:
+		wh.handleHello(client, msg.Data)
+	case protocol.TypeSelectCard:
 		wh.handleSelectCard(client, msg.Data)
-	case "start_game":
+	case protocol.TypeStartGame:
 		wh.handleStartGame(client)
-	case "get_state":
+	case protocol.TypeGetState, protocol.TypeResync:
 		wh.handleGetState(client)
+	case protocol.TypeLeaveGame:
+		wh.handleLeaveGame(client)
+	case protocol.TypeKickPlayer:
+		wh.handleKickPlayer(client, msg.Data)
+	case protocol.TypeTransferHost:
+		wh.handleTransferHost(client, msg.Data)
+	case protocol.TypeChatMessage:
+		wh.handleChatMessage(client, msg.Data)
+	case protocol.TypeReaction:
+		wh.handleReaction(client, msg.Data)
+	case protocol.TypeCreateInvite:
+		wh.handleCreateInvite(client)
 	default:
-		log.Printf("Unknown message type: %s", msg.Type)
+		client.log.Warn("unknown message type", "type", msg.Type)
+		wh.sendError(client, protocol.ErrCodeUnknownType, fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+}
+
+// decodeData unmarshals a message payload, reporting malformed data to the client
+func (wh *WSHandler) decodeData(client *Client, msgType string, data json.RawMessage, v interface{}) bool {
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		client.log.Warn("invalid message data", "type", msgType, "err", err)
+		wh.sendError(client, protocol.ErrCodeBadMessage, fmt.Sprintf("invalid %s data", msgType))
+		return false
 	}
+	return true
+}
+
+// handleHello checks the client's protocol version
+func (wh *WSHandler) handleHello(client *Client, data json.RawMessage) {
+	var hello protocol.HelloData
+	if !wh.decodeData(client, protocol.TypeHello, data, &hello) {
+		return
+	}
+
+	if hello.ProtocolVersion != protocol.Version {
+		wh.sendError(client, protocol.ErrCodeUnsupportedVersion,
+			fmt.Sprintf("protocol version %d is not supported (server speaks %d)", hello.ProtocolVersion, protocol.Version))
+		return
+	}
+
+	wh.sendToClient(client, protocol.TypeWelcome, protocol.WelcomeData{
+		ProtocolVersion: protocol.Version,
+		GameID:          client.GameID,
+		PlayerID:        client.ID,
+	})
 }
 
 // handleSelectCard processes card selection
 func (wh *WSHandler) handleSelectCard(client *Client, data json.RawMessage) {
-	var selectData SelectCardData
-	if err := json.Unmarshal(data, &selectData); err != nil {
-		log.Printf("Error unmarshaling select card data: %v", err)
+	var selectData protocol.SelectCardData
+	if !wh.decodeData(client, protocol.TypeSelectCard, data, &selectData) {
 		return
 	}
 
+	log := client.log.With("type", protocol.TypeSelectCard)
+
 	g, err := wh.gameManager.GetGame(client.GameID)
 	if err != nil {
-		log.Printf("Game not found: %v", err)
+		log.Info("select card rejected", "err", err)
+		wh.sendErr(client, err)
 		return
 	}
 
 	// Select card
 	err = game.SelectCard(g, client.ID, selectData.CardIndex)
 	if err != nil {
-		log.Printf("Error selecting card: %v", err)
-		wh.sendError(client, err.Error())
+		log.Info("select card rejected", "card_index", selectData.CardIndex, "err", err)
+		wh.sendErr(client, err)
 		return
 	}
+	log.Debug("card selected", "round", g.Round, "turn", g.Turn)
 
 	// Broadcast game state
 	wh.broadcastGameState(client.GameID)
 
-	// If all players selected, pass hands
-	if g.AllPlayersSelected() {
-		err = game.PassHands(g)
+	wh.advanceTurn(g)
+}
+
+// advanceTurn lets bots pick and passes hands once every player has selected
+func (wh *WSHandler) advanceTurn(g *models.Game) {
+	for {
+		game.SelectForBots(g)
+		if g.State != models.StatePlaying || !g.AllPlayersSelected() {
+			break
+		}
+
+		log := wh.log.With("game_id", g.ID)
+		round := g.Round
+		err := game.PassHands(g)
 		if err != nil {
-			log.Printf("Error passing hands: %v", err)
+			log.Error("passing hands failed", "round", g.Round, "turn", g.Turn, "err", err)
 			return
 		}
-		wh.broadcastGameState(client.GameID)
+		if g.State == models.StateFinished {
+			duration := g.FinishedAt.Sub(g.StartedAt)
+			wh.hub.metrics.gameSeconds.Observe(duration.Seconds())
+			log.Info("game finished", "duration", duration.Round(time.Second), "scores", scoreSummary(g))
+		} else if g.Round != round {
+			log.Info("round finished", "round", round, "scores", scoreSummary(g))
+		}
+		wh.broadcastGameState(g.ID)
+	}
+	wh.startTurnTimer(g)
+}
+
+// startTurnTimer picks a random card for anyone who hasn't selected when the turn times out
+func (wh *WSHandler) startTurnTimer(g *models.Game) {
+	if wh.turnTimeout <= 0 || g.State != models.StatePlaying {
+		return
 	}
+
+	gameID, round, turn := g.ID, g.Round, g.Turn
+	time.AfterFunc(wh.turnTimeout, func() {
+		defer wh.gameManager.LockGame(gameID)()
+
+		g, err := wh.gameManager.GetGame(gameID)
+		if err != nil || g.State != models.StatePlaying || g.Round != round || g.Turn != turn {
+			return
+		}
+
+		for id, player := range g.Players {
+			if !player.HasSelected && len(player.Hand) > 0 {
+				if err := game.AutoSelect(g, id); err != nil {
+					wh.log.Error("auto-select failed", "game_id", gameID, "player_id", id, "err", err)
+				} else {
+					wh.log.Info("turn timed out, card picked automatically", "game_id", gameID, "player_id", id, "round", round, "turn", turn)
+				}
+			}
+		}
+
+		wh.broadcastGameState(gameID)
+		wh.advanceTurn(g)
+	})
 }
 
 // handleStartGame starts the game
 func (wh *WSHandler) handleStartGame(client *Client) {
+	log := client.log.With("type", protocol.TypeStartGame)
+
 	g, err := wh.gameManager.GetGame(client.GameID)
 	if err != nil {
-		log.Printf("Game not found: %v", err)
+		log.Info("start game rejected", "err", err)
+		wh.sendErr(client, err)
 		return
 	}
 
 	// Only host can start
 	if g.HostID != client.ID {
-		wh.sendError(client, "only host can start the game")
+		log.Info("start game rejected", "err", ErrNotHost)
+		wh.sendError(client, protocol.ErrCodeNotHost, "only host can start the game")
 		return
 	}
 
 	err = game.StartGame(g)
 	if err != nil {
-		log.Printf("Error starting game: %v", err)
-		wh.sendError(client, err.Error())
+		log.Info("start game rejected", "err", err)
+		wh.sendErr(client, err)
 		return
 	}
+	log.Info("game started", "players", len(g.Players))
 
 	wh.broadcastGameState(client.GameID)
+	wh.startTurnTimer(g)
 }
 
-// handleGetState sends current game state to client
-func (wh *WSHandler) handleGetState(client *Client) {
+// handleLeaveGame removes the client's player from the game
+func (wh *WSHandler) handleLeaveGame(client *Client) {
+	wh.removePlayer(client, client.ID, protocol.LeaveReasonLeft)
+}
+
+// handleKickPlayer lets the host remove another player
+func (wh *WSHandler) handleKickPlayer(client *Client, data json.RawMessage) {
+	var kickData protocol.KickPlayerData
+	if !wh.decodeData(client, protocol.TypeKickPlayer, data, &kickData) {
+		return
+	}
+
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return
+	}
+
+	if g.HostID != client.ID {
+		wh.sendError(client, protocol.ErrCodeNotHost, "only host can kick players")
+		return
+	}
+
+	if kickData.PlayerID == client.ID {
+		wh.sendError(client, protocol.ErrCodeInvalidAction, "host cannot kick themselves")
+		return
+	}
+
+	wh.removePlayer(client, kickData.PlayerID, protocol.LeaveReasonKicked)
+}
+
+// removePlayer takes a player out of the game, tells everyone and disconnects them
+func (wh *WSHandler) removePlayer(client *Client, playerID, reason string) {
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return
+	}
+
+	result, err := wh.gameManager.LeaveGame(client.GameID, playerID)
+	if err != nil {
+		client.log.Info("remove player rejected", "type", reasonType(reason), "target_id", playerID, "err", err)
+		wh.sendErr(client, err)
+		return
+	}
+	if reason == protocol.LeaveReasonKicked {
+		client.log.Info("player kicked", "type", protocol.TypeKickPlayer, "target_id", playerID)
+	}
+
+	wh.hub.BroadcastToGame(client.GameID, protocol.TypePlayerLeft, protocol.PlayerLeftData{
+		PlayerID:      playerID,
+		PlayerName:    result.PlayerName,
+		Reason:        reason,
+		ReplacedByBot: result.ReplacedByBot,
+		NewHostID:     result.NewHostID,
+	})
+	wh.hub.ClosePlayer(client.GameID, playerID)
+
+	if result.GameEnded {
+		wh.hub.CloseGame(client.GameID)
+		return
+	}
+
+	if result.NewHostID != "" {
+		wh.announceHost(g, protocol.HostReasonLeft)
+	}
+
+	wh.broadcastGameState(client.GameID)
+	if result.ReplacedByBot {
+		wh.advanceTurn(g)
+	}
+}
+
+// handleTransferHost lets the host hand the host role to another player
+func (wh *WSHandler) handleTransferHost(client *Client, data json.RawMessage) {
+	var transferData protocol.TransferHostData
+	if !wh.decodeData(client, protocol.TypeTransferHost, data, &transferData) {
+		return
+	}
+
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return
+	}
+
+	err = wh.gameManager.TransferHost(client.GameID, client.ID, transferData.PlayerID)
+	if err != nil {
+		client.log.Info("transfer host rejected", "type", protocol.TypeTransferHost, "target_id", transferData.PlayerID, "err", err)
+		wh.sendErr(client, err)
+		return
+	}
+
+	wh.announceHost(g, protocol.HostReasonTransferred)
 	wh.broadcastGameState(client.GameID)
 }
 
-// broadcastGameState sends game state to all clients
+// handleCreateInvite sends the host an invite token for their game
+func (wh *WSHandler) handleCreateInvite(client *Client) {
+	token, expires, err := wh.gameManager.Invite(client.GameID, client.ID)
+	if err != nil {
+		client.log.Info("create invite rejected", "type", protocol.TypeCreateInvite, "err", err)
+		wh.sendErr(client, err)
+		return
+	}
+
+	wh.sendToClient(client, protocol.TypeInvite, protocol.InviteData{
+		GameID:    client.GameID,
+		Token:     token,
+		ExpiresAt: expires.UnixMilli(),
+	})
+}
+
+// EndGame finishes a game early and sends everyone the final scores
+func (wh *WSHandler) EndGame(gameID string) error {
+	defer wh.gameManager.LockGame(gameID)()
+
+	if _, err := wh.gameManager.EndGame(gameID); err != nil {
+		return err
+	}
+
+	wh.hub.BroadcastToGame(gameID, protocol.TypeServerNotice, protocol.ServerNoticeData{
+		Message: "the server operator ended this game",
+	})
+	wh.broadcastGameState(gameID)
+	return nil
+}
+
+// HandleDisconnect updates the game after a client's connection closes
+func (wh *WSHandler) HandleDisconnect(client *Client) {
+	defer wh.gameManager.LockGame(client.GameID)()
+
+	if wh.gameManager.MarkDisconnected(client.GameID, client.ID) == "" {
+		return
+	}
+
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		return
+	}
+
+	wh.announceHost(g, protocol.HostReasonDisconnected)
+	wh.broadcastGameState(client.GameID)
+}
+
+// handleChatMessage relays a chat message to the room and records it in the history
+func (wh *WSHandler) handleChatMessage(client *Client, data json.RawMessage) {
+	var chatMsg protocol.ChatMessageData
+	if !wh.decodeData(client, protocol.TypeChatMessage, data, &chatMsg) {
+		return
+	}
+
+	player, ok := wh.chatter(client)
+	if !ok {
+		return
+	}
+
+	text, err := chat.Clean(chatMsg.Text, wh.chatMaxLength, *wh.chatFilter.Load())
+	if err != nil {
+		client.log.Info("chat rejected", "type", protocol.TypeChatMessage, "err", err)
+		wh.sendErr(client, err)
+		return
+	}
+
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return
+	}
+
+	msg := models.ChatMessage{
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+		Text:       text,
+		SentAt:     time.Now(),
+	}
+	g.AddChat(msg, wh.chatHistory)
+	client.log.Debug("chat relayed", "type", protocol.TypeChatMessage, "length", len(text))
+	wh.hub.BroadcastToGame(client.GameID, protocol.TypeChatMessage, chatData(msg))
+}
+
+// handleReaction relays an emoji reaction to the room
+func (wh *WSHandler) handleReaction(client *Client, data json.RawMessage) {
+	var reaction protocol.ReactionData
+	if !wh.decodeData(client, protocol.TypeReaction, data, &reaction) {
+		return
+	}
+
+	player, ok := wh.chatter(client)
+	if !ok {
+		return
+	}
+
+	if !chat.IsReaction(reaction.Emoji) {
+		wh.sendErr(client, chat.ErrUnknownReaction)
+		return
+	}
+
+	wh.hub.BroadcastToGame(client.GameID, protocol.TypeReaction, protocol.ReactionBroadcastData{
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+		Emoji:      reaction.Emoji,
+	})
+}
+
+// chatter returns the player behind a chat message or reaction if they are
+// still in the game and within the chat rate limit
+func (wh *WSHandler) chatter(client *Client) (*models.Player, bool) {
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return nil, false
+	}
+
+	player, exists := g.Players[client.ID]
+	if !exists {
+		wh.sendErr(client, game.ErrPlayerNotFound)
+		return nil, false
+	}
+
+	if ok, retryAfter := wh.chatLimiter.Allow(client.GameID + "/" + client.ID); !ok {
+		client.log.Debug("chat rate limited")
+		wh.sendError(client, protocol.ErrCodeRateLimited, fmt.Sprintf("chatting too fast, retry in %s", retryAfter.Round(time.Second)))
+		return nil, false
+	}
+	return player, true
+}
+
+// chatData converts a stored chat message for the wire
+func chatData(msg models.ChatMessage) protocol.ChatBroadcastData {
+	return protocol.ChatBroadcastData{
+		PlayerID:   msg.PlayerID,
+		PlayerName: msg.PlayerName,
+		Text:       msg.Text,
+		SentAt:     msg.SentAt.UnixMilli(),
+	}
+}
+
+// announceHost tells everyone in the game who the host is now
+func (wh *WSHandler) announceHost(g *models.Game, reason string) {
+	hostName := ""
+	if host, exists := g.Players[g.HostID]; exists {
+		hostName = host.Name
+	}
+
+	wh.hub.BroadcastToGame(g.ID, protocol.TypeHostChanged, protocol.HostChangedData{
+		HostID:   g.HostID,
+		HostName: hostName,
+		Reason:   reason,
+	})
+}
+
+// handleGetState sends a full snapshot of the game to the client. It also
+// answers resync, which clients send when they notice a gap in patches.
+func (wh *WSHandler) handleGetState(client *Client) {
+	g, err := wh.gameManager.GetGame(client.GameID)
+	if err != nil {
+		wh.sendErr(client, err)
+		return
+	}
+
+	state := createPlayerGameState(g, client.ID)
+	wh.hub.BroadcastEach(client.GameID, func(c *Client) []byte {
+		if c != client {
+			return nil
+		}
+		return stateUpdate(c, state, true)
+	})
+}
+
+// broadcastGameState sends every client the changes to its view of the game
 func (wh *WSHandler) broadcastGameState(gameID string) {
 	g, err := wh.gameManager.GetGame(gameID)
 	if err != nil {
@@ -126,73 +553,113 @@ func (wh *WSHandler) broadcastGameState(gameID string) {
 	}
 
 	// Create sanitized state for each player (hide other players' hands)
-	wh.hub.mu.RLock()
-	for _, client := range wh.hub.clients {
-		if client.GameID == gameID {
-			state := createPlayerGameState(g, client.ID)
-			wh.sendToClient(client, "game_state", state)
+	wh.hub.BroadcastEach(gameID, func(client *Client) []byte {
+		return stateUpdate(client, createPlayerGameState(g, client.ID), false)
+	})
+}
+
+// stateUpdate returns the message that brings a client from the last state
+// it was sent to state: a snapshot the first time or when forced, otherwise
+// a patch, or nil if nothing changed. It runs under the client's room lock,
+// which guards the client's sync state.
+func stateUpdate(c *Client, state protocol.GameState, snapshot bool) []byte {
+	value, err := jsonpatch.ToValue(state)
+	if err != nil {
+		c.log.Error("encoding state failed", "err", err)
+		return nil
+	}
+
+	var msgType string
+	var data interface{}
+	if snapshot || c.lastState == nil {
+		msgType = protocol.TypeStateSnapshot
+		data = protocol.StateSnapshotData{Seq: c.seq + 1, State: state}
+	} else {
+		ops := jsonpatch.Diff(c.lastState, value)
+		if len(ops) == 0 {
+			return nil
 		}
+		msgType = protocol.TypeStatePatch
+		data = protocol.StatePatchData{Seq: c.seq + 1, Ops: ops}
+	}
+
+	jsonMsg, err := marshalMessage(msgType, data)
+	if err != nil {
+		return nil
 	}
-	wh.hub.mu.RUnlock()
+	c.seq++
+	c.lastState = value
+	return jsonMsg
 }
 
 // sendToClient sends a message to a specific client
 func (wh *WSHandler) sendToClient(client *Client, messageType string, data interface{}) {
-	message := map[string]interface{}{
-		"type": messageType,
-		"data": data,
-	}
+	wh.hub.Send(client, messageType, data)
+}
 
-	jsonMsg, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
-		return
+// sendError sends an error message to client
+func (wh *WSHandler) sendError(client *Client, code protocol.ErrorCode, errorMsg string) {
+	client.log.Debug("error sent", "code", code, "message", errorMsg)
+	wh.sendToClient(client, protocol.TypeError, protocol.ErrorData{Code: code, Message: errorMsg})
+}
+
+// reasonType returns the message type that removes a player for a reason
+func reasonType(reason string) string {
+	if reason == protocol.LeaveReasonKicked {
+		return protocol.TypeKickPlayer
 	}
+	return protocol.TypeLeaveGame
+}
 
-	select {
-	case client.Send <- jsonMsg:
-	default:
-		log.Printf("Client send buffer full")
+// scoreSummary lists each player's score for logging
+func scoreSummary(g *models.Game) map[string]int {
+	scores := make(map[string]int, len(g.Players))
+	for id, p := range g.Players {
+		scores[id] = p.Score
 	}
+	return scores
 }
 
-// sendError sends an error message to client
-func (wh *WSHandler) sendError(client *Client, errorMsg string) {
-	wh.sendToClient(client, "error", map[string]string{"message": errorMsg})
+// sendErr sends an engine or manager error to client with its error code
+func (wh *WSHandler) sendErr(client *Client, err error) {
+	wh.sendError(client, errorCode(err), err.Error())
 }
 
 // createPlayerGameState creates a game state with hidden information for other players
-func createPlayerGameState(g *models.Game, playerID string) map[string]interface{} {
+func createPlayerGameState(g *models.Game, playerID string) protocol.GameState {
 	// Create players list with hidden hands
-	players := make([]map[string]interface{}, 0)
-	for id, p := range g.Players {
-		playerData := map[string]interface{}{
-			"id":            p.ID,
-			"name":          p.Name,
-			"score":         p.Score,
-			"round_scores":  p.RoundScores,
-			"has_selected":  p.HasSelected,
-			"played_cards":  p.PlayedCards,
-			"hand_size":     len(p.Hand),
+	players := make([]protocol.PlayerState, 0, len(g.Players))
+	for _, p := range g.Seats() {
+		playerData := protocol.PlayerState{
+			ID:          p.ID,
+			Name:        p.Name,
+			Score:       p.Score,
+			RoundScores: p.RoundScores,
+			HasSelected: p.HasSelected,
+			PlayedCards: p.PlayedCards,
+			HandSize:    len(p.Hand),
+			IsBot:       p.IsBot,
+			Connections: p.Connections,
 		}
 
 		// Only show full hand to the player themselves
-		if id == playerID {
-			playerData["hand"] = p.Hand
-			playerData["is_me"] = true
-		} else {
-			playerData["is_me"] = false
+		if p.ID == playerID {
+			playerData.Hand = p.Hand
+			playerData.IsMe = true
 		}
 
 		players = append(players, playerData)
 	}
 
-	return map[string]interface{}{
-		"id":      g.ID,
-		"state":   g.State,
-		"round":   g.Round,
-		"turn":    g.Turn,
-		"host_id": g.HostID,
-		"players": players,
+	return protocol.GameState{
+		ID:         g.ID,
+		State:      g.State,
+		Round:      g.Round,
+		Turn:       g.Turn,
+		HostID:     g.HostID,
+		MinPlayers: g.MinPlayers,
+		MaxPlayers: g.MaxPlayers,
+		Private:    g.Private(),
+		Players:    players,
 	}
 }
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"testing"
+
+	"github.com/aiplaybookin/tiffin-go/internal/config"
+	"github.com/aiplaybookin/tiffin-go/internal/game"
+	"github.com/aiplaybookin/tiffin-go/internal/protocol"
+)
+
+// benchServer creates a server with games started games of four players,
+// each player with a connected client whose messages are thrown away
+func benchServer(b *testing.B, games int) (*Server, []string) {
+	b.Helper()
+
+	cfg := config.Default()
+	cfg.MaxGames, cfg.MaxTotalPlayers = 0, 0
+	s := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ids := make([]string, games)
+	for i := range ids {
+		host := newPlayerID()
+		g, err := s.gameManager.CreateGame(host, "Host", "")
+		if err != nil {
+			b.Fatalf("create game: %v", err)
+		}
+		players := []string{host}
+		for j := 1; j < 4; j++ {
+			id := newPlayerID()
+			if _, err := s.gameManager.JoinGame(g.ID, id, fmt.Sprintf("Player %d", j)); err != nil {
+				b.Fatalf("join game: %v", err)
+			}
+			players = append(players, id)
+		}
+		if err := game.StartGame(g); err != nil {
+			b.Fatalf("start game: %v", err)
+		}
+
+		for _, id := range players {
+			c := NewClient(id, g.ID, nil, s.connLimits, s.log)
+			s.hub.Register(c)
+			go func() {
+				for range c.Send {
+				}
+			}()
+			b.Cleanup(func() { s.hub.Unregister(c) })
+		}
+		ids[i] = g.ID
+	}
+	return s, ids
+}
+
+// BenchmarkBroadcastGameState measures a game action and its state broadcast
+// while every game is busy at once. Each action takes only its own game's
+// lock, so with more games the goroutines rarely wait on each other.
+func BenchmarkBroadcastGameState(b *testing.B) {
+	for _, games := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("games=%d", games), func(b *testing.B) {
+			s, ids := benchServer(b, games)
+			wh := s.wsHandler
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					gameID := ids[rng.Intn(len(ids))]
+
+					unlock := s.gameManager.LockGame(gameID)
+					g, _ := s.gameManager.GetGame(gameID)
+					host := g.Players[g.HostID]
+					host.HasSelected = !host.HasSelected // Something to patch
+					wh.broadcastGameState(gameID)
+					unlock()
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkBroadcastToGame measures room broadcasts that need no game lock,
+// such as chat, for comparison
+func BenchmarkBroadcastToGame(b *testing.B) {
+	for _, games := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("games=%d", games), func(b *testing.B) {
+			s, ids := benchServer(b, games)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					s.hub.BroadcastToGame(ids[rng.Intn(len(ids))], protocol.TypeServerNotice, protocol.ServerNoticeData{Message: "hi"})
+				}
+			})
+		})
+	}
+}
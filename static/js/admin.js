@@ -0,0 +1,231 @@
+// Admin dashboard for the /admin API
+
+// How often the game list refreshes
+const REFRESH_INTERVAL = 5000;
+
+let adminToken = sessionStorage.getItem('adminToken');
+let refreshTimer = null;
+
+// Screen management
+function showScreen(screenId) {
+    document.querySelectorAll('.screen').forEach(screen => {
+        screen.classList.remove('active');
+    });
+    document.getElementById(screenId).classList.add('active');
+}
+
+// Error handling
+function showError(message) {
+    const errorEl = document.getElementById('errorMessage');
+    errorEl.textContent = message;
+    errorEl.classList.add('show');
+    setTimeout(() => {
+        errorEl.classList.remove('show');
+    }, 5000);
+}
+
+// Call the admin API, signing out if the token is rejected
+async function api(method, path, body) {
+    const options = {
+        method,
+        headers: { 'Authorization': `Bearer ${adminToken}` }
+    };
+    if (body !== undefined) {
+        options.headers['Content-Type'] = 'application/json';
+        options.body = JSON.stringify(body);
+    }
+
+    const response = await fetch(path, options);
+    if (response.status === 401) {
+        signOut();
+        throw new Error('That admin token was not accepted');
+    }
+    if (response.status === 404 && path === '/admin/games') {
+        throw new Error('The admin API is disabled on this server');
+    }
+    if (!response.ok) {
+        let message = `Request failed (${response.status})`;
+        try {
+            message = (await response.json()).message || message;
+        } catch (e) {
+            // Keep the status message
+        }
+        throw new Error(message);
+    }
+
+    return response.status === 204 ? null : response.json();
+}
+
+function signIn(token) {
+    adminToken = token;
+    sessionStorage.setItem('adminToken', token);
+    showScreen('dashboardScreen');
+    refresh();
+    refreshTimer = setInterval(refresh, REFRESH_INTERVAL);
+}
+
+function signOut() {
+    adminToken = null;
+    sessionStorage.removeItem('adminToken');
+    clearInterval(refreshTimer);
+    showScreen('signInScreen');
+}
+
+// Reload the game and connection lists
+async function refresh() {
+    try {
+        const [games, connections] = await Promise.all([
+            api('GET', '/admin/games'),
+            api('GET', '/admin/connections')
+        ]);
+        renderGames(games);
+        renderConnections(connections);
+    } catch (e) {
+        showError(e.message);
+    }
+}
+
+function renderGames(data) {
+    document.getElementById('adminTotals').textContent =
+        `${data.games.length} games · ${data.players} players · ${data.clients} connections`;
+
+    const body = document.getElementById('gamesBody');
+    body.innerHTML = '';
+
+    if (data.games.length === 0) {
+        const row = body.insertRow();
+        const cell = row.insertCell();
+        cell.colSpan = 6;
+        cell.textContent = 'No games running';
+        return;
+    }
+
+    data.games.forEach(game => {
+        const row = body.insertRow();
+        row.insertCell().textContent = game.private ? `${game.id} 🔒` : game.id;
+        row.insertCell().textContent = game.state;
+        row.insertCell().textContent = game.round > 0 ? `${game.round} (turn ${game.turn})` : '-';
+        row.insertCell().textContent = game.players.map(playerLabel).join(', ');
+        row.insertCell().textContent = idleText(game.last_activity);
+
+        const actions = row.insertCell();
+        actions.className = 'admin-actions';
+        actions.appendChild(actionButton('Inspect', () => inspectGame(game.id)));
+        if (game.state !== 'finished') {
+            actions.appendChild(actionButton('End', () => endGame(game.id)));
+        }
+        actions.appendChild(actionButton('Delete', () => deleteGame(game.id)));
+    });
+}
+
+function renderConnections(data) {
+    const body = document.getElementById('connectionsBody');
+    body.innerHTML = '';
+
+    if (data.connections.length === 0) {
+        const row = body.insertRow();
+        const cell = row.insertCell();
+        cell.colSpan = 8;
+        cell.textContent = 'No open connections';
+        return;
+    }
+
+    data.connections.forEach(conn => {
+        const row = body.insertRow();
+        row.insertCell().textContent = conn.player_id.slice(0, 8);
+        row.insertCell().textContent = conn.game_id;
+        row.insertCell().textContent = conn.remote_addr || 'relayed';
+        row.insertCell().textContent = idleText(conn.connected_at);
+        row.insertCell().textContent = `${idleText(conn.last_seen)} ago`;
+        row.insertCell().textContent = conn.rtt_ns > 0 ? `${Math.round(conn.rtt_ns / 1e6)} ms` : '-';
+        row.insertCell().textContent = `${conn.messages_in} / ${conn.messages_out}`;
+        row.insertCell().textContent = conn.queued;
+    });
+}
+
+// Describe a player as "Name (score)", marking bots and disconnected players
+function playerLabel(player) {
+    let label = `${player.name} (${player.score})`;
+    if (player.is_bot) {
+        label += ' 🤖';
+    } else if (player.connections === 0) {
+        label += ' ⚪';
+    }
+    return label;
+}
+
+function idleText(lastActivity) {
+    const seconds = Math.max(0, Math.round((Date.now() - new Date(lastActivity)) / 1000));
+    if (seconds < 60) return `${seconds}s`;
+    if (seconds < 3600) return `${Math.floor(seconds / 60)}m`;
+    return `${Math.floor(seconds / 3600)}h ${Math.floor(seconds % 3600 / 60)}m`;
+}
+
+function actionButton(label, onClick) {
+    const btn = document.createElement('button');
+    btn.className = 'btn btn-secondary';
+    btn.textContent = label;
+    btn.addEventListener('click', onClick);
+    return btn;
+}
+
+async function inspectGame(gameId) {
+    try {
+        const game = await api('GET', `/admin/games/${encodeURIComponent(gameId)}`);
+        const detail = document.getElementById('gameDetail');
+        detail.textContent = JSON.stringify(game, null, 2);
+        detail.style.display = 'block';
+    } catch (e) {
+        showError(e.message);
+    }
+}
+
+async function endGame(gameId) {
+    if (!confirm(`End game ${gameId} now? Players will see the current scores as final.`)) return;
+    try {
+        await api('POST', `/admin/games/${encodeURIComponent(gameId)}/end`);
+        refresh();
+    } catch (e) {
+        showError(e.message);
+    }
+}
+
+async function deleteGame(gameId) {
+    if (!confirm(`Delete game ${gameId}? Everyone in it will be disconnected.`)) return;
+    try {
+        await api('DELETE', `/admin/games/${encodeURIComponent(gameId)}`);
+        document.getElementById('gameDetail').style.display = 'none';
+        refresh();
+    } catch (e) {
+        showError(e.message);
+    }
+}
+
+document.getElementById('signInForm').addEventListener('submit', (e) => {
+    e.preventDefault();
+    const token = document.getElementById('adminToken').value.trim();
+    if (token) {
+        signIn(token);
+    }
+});
+
+document.getElementById('signOutBtn').addEventListener('click', signOut);
+document.getElementById('refreshBtn').addEventListener('click', refresh);
+
+document.getElementById('noticeForm').addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const input = document.getElementById('noticeText');
+    const message = input.value.trim();
+    if (!message) return;
+
+    try {
+        await api('POST', '/admin/notice', { message });
+        input.value = '';
+    } catch (e) {
+        showError(e.message);
+    }
+});
+
+if (adminToken) {
+    signIn(adminToken);
+}
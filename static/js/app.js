@@ -3,9 +3,20 @@ let gameState = {
     gameId: null,
     playerId: null,
     ws: null,
-    currentGame: null
+    currentGame: null,
+    stateSeq: null, // Last state message applied; null while waiting for a snapshot
+    unread: 0 // Chat messages received while the panel is collapsed
 };
 
+// WebSocket protocol version spoken by this client
+const PROTOCOL_VERSION = 2;
+
+// Invite token from the link this page was opened with, used by the next join
+let pendingInvite = null;
+
+// localStorage key for the last name used, so invite links can join straight away
+const PLAYER_NAME_KEY = 'tiffinPlayerName';
+
 // Card emojis
 const cardEmojis = {
     'samosa': '🥟',
@@ -27,6 +38,46 @@ const cardNames = {
     'raita': 'Raita'
 };
 
+// User-facing text for server error codes
+const errorMessages = {
+    'game_not_found': 'That game does not exist',
+    'game_full': 'That game is full',
+    'game_started': 'That game has already started',
+    'not_enough_players': 'Not enough players to start',
+    'not_host': 'Only the host can do that',
+    'not_your_turn': 'You have already picked a card this turn',
+    'invalid_card_index': 'That card is no longer in your hand',
+    'game_not_playing': 'The game is not in progress',
+    'server_full': 'The server is busy, please try again later',
+    'shutting_down': 'The server is restarting, please try again shortly',
+    'rate_limited': 'You are doing that too often, please slow down',
+    'message_too_long': 'That message is too long',
+    'chat_rejected': 'That message was not allowed',
+    'game_finished': 'That game has already finished',
+    'password_required': 'That room is private, please enter its password',
+    'wrong_password': 'Wrong room password',
+    'invalid_invite': 'That invite link is not valid',
+    'invite_expired': 'That invite link has expired, ask the host for a new one'
+};
+
+// Emoji players can react with (must match the server's list)
+const reactions = ['👍', '👏', '😂', '😮', '😢', '🔥', '🍛', '☕'];
+
+// Turn a server error payload into text for the player
+function errorText(error, fallback) {
+    if (!error) return fallback;
+    return errorMessages[error.code] || error.message || fallback;
+}
+
+// Read the error payload from a failed API response
+async function responseError(response, fallback) {
+    try {
+        return errorText(await response.json(), fallback);
+    } catch (e) {
+        return fallback;
+    }
+}
+
 // Screen management
 function showScreen(screenId) {
     document.querySelectorAll('.screen').forEach(screen => {
@@ -57,21 +108,24 @@ document.getElementById('joinGameBtn').addEventListener('click', () => {
 // Create game
 document.getElementById('confirmCreateBtn').addEventListener('click', async () => {
     const playerName = document.getElementById('createPlayerName').value.trim() || 'Player';
+    const password = document.getElementById('createPassword').value;
 
     try {
         const response = await fetch('/api/create', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ player_name: playerName })
+            body: JSON.stringify({ player_name: playerName, password })
         });
 
         if (!response.ok) {
-            throw new Error('Failed to create game');
+            throw new Error(await responseError(response, 'Failed to create game'));
         }
 
         const data = await response.json();
         gameState.gameId = data.game_id;
         gameState.playerId = data.player_id;
+        localStorage.setItem(PLAYER_NAME_KEY, playerName);
+        document.getElementById('createPassword').value = '';
 
         connectWebSocket();
         showLobby();
@@ -100,18 +154,30 @@ document.getElementById('confirmJoinBtn').addEventListener('click', async () =>
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
                 game_id: gameId,
-                player_name: playerName
+                player_name: playerName,
+                password: document.getElementById('joinPassword').value,
+                invite: pendingInvite || undefined
             })
         });
 
         if (!response.ok) {
-            const error = await response.text();
-            throw new Error(error || 'Failed to join game');
+            const error = await response.json().catch(() => null);
+            if (error && (error.code === 'invalid_invite' || error.code === 'invite_expired')) {
+                // Fall back to the password
+                setInvite(null);
+            }
+            if (error && (error.code === 'password_required' || error.code === 'wrong_password')) {
+                document.getElementById('joinPassword').focus();
+            }
+            throw new Error(errorText(error, 'Failed to join game'));
         }
 
         const data = await response.json();
         gameState.gameId = data.game_id;
         gameState.playerId = data.player_id;
+        localStorage.setItem(PLAYER_NAME_KEY, playerName);
+        document.getElementById('joinPassword').value = '';
+        setInvite(null);
 
         connectWebSocket();
         showLobby();
@@ -121,9 +187,53 @@ document.getElementById('confirmJoinBtn').addEventListener('click', async () =>
 });
 
 document.getElementById('cancelJoinBtn').addEventListener('click', () => {
+    setInvite(null);
     showScreen('homeScreen');
 });
 
+// Remember an invite token for the next join; an invite replaces the password
+function setInvite(token) {
+    pendingInvite = token;
+    document.getElementById('joinInviteNote').style.display = token ? 'block' : 'none';
+    document.getElementById('joinPasswordGroup').style.display = token ? 'none' : 'block';
+}
+
+// Open the join screen for a shared link (?game=CODE&invite=TOKEN), joining
+// straight away if we remember the player's name
+function openInviteLink() {
+    const params = new URLSearchParams(window.location.search);
+    const gameId = params.get('game');
+    if (!gameId) return;
+
+    // Drop the token from the address bar so a reload doesn't join again
+    history.replaceState(null, '', window.location.pathname);
+
+    document.getElementById('joinGameId').value = gameId;
+    setInvite(params.get('invite'));
+    showScreen('joinGameScreen');
+
+    const savedName = localStorage.getItem(PLAYER_NAME_KEY);
+    if (savedName) {
+        document.getElementById('joinPlayerName').value = savedName;
+        document.getElementById('confirmJoinBtn').click();
+    } else {
+        document.getElementById('joinPlayerName').focus();
+    }
+}
+
+// Build a shareable link from an invite and copy it to the clipboard
+function copyInviteLink(invite) {
+    const url = new URL(window.location.pathname, window.location.origin);
+    url.searchParams.set('game', invite.game_id);
+    url.searchParams.set('invite', invite.token);
+    const expires = new Date(invite.expires_at).toLocaleString([], { dateStyle: 'medium', timeStyle: 'short' });
+
+    navigator.clipboard.writeText(url.toString()).then(
+        () => showError(`Invite link copied! It works until ${expires}`),
+        () => window.prompt('Copy this invite link:', url.toString())
+    );
+}
+
 // Copy game code
 document.getElementById('copyCodeBtn').addEventListener('click', () => {
     const code = document.getElementById('gameCodeDisplay').textContent;
@@ -136,6 +246,11 @@ document.getElementById('startGameBtn').addEventListener('click', () => {
     sendWebSocketMessage('start_game', {});
 });
 
+// Ask the server for an invite link
+document.getElementById('inviteBtn').addEventListener('click', () => {
+    sendWebSocketMessage('create_invite', {});
+});
+
 // Leave lobby
 document.getElementById('leaveLobbyBtn').addEventListener('click', () => {
     leaveGame();
@@ -147,6 +262,33 @@ document.getElementById('leaveGameBtn').addEventListener('click', () => {
 });
 
 // Back to home
+document.getElementById('chatToggle').addEventListener('click', () => {
+    const panel = document.getElementById('chatPanel');
+    panel.classList.toggle('collapsed');
+    if (!panel.classList.contains('collapsed')) {
+        setUnread(0);
+        document.getElementById('chatInput').focus();
+    }
+});
+
+document.getElementById('chatForm').addEventListener('submit', (e) => {
+    e.preventDefault();
+    const input = document.getElementById('chatInput');
+    const text = input.value.trim();
+    if (text) {
+        sendWebSocketMessage('chat_message', { text });
+        input.value = '';
+    }
+});
+
+reactions.forEach(emoji => {
+    const btn = document.createElement('button');
+    btn.className = 'reaction-btn';
+    btn.textContent = emoji;
+    btn.addEventListener('click', () => sendWebSocketMessage('reaction', { emoji }));
+    document.getElementById('reactionBar').appendChild(btn);
+});
+
 document.getElementById('backToHomeBtn').addEventListener('click', () => {
     showScreen('homeScreen');
 });
@@ -157,9 +299,11 @@ function connectWebSocket() {
     const wsUrl = `${protocol}//${window.location.host}/ws?game_id=${gameState.gameId}&player_id=${gameState.playerId}`;
 
     gameState.ws = new WebSocket(wsUrl);
+    document.getElementById('chatPanel').style.display = '';
 
     gameState.ws.onopen = () => {
         console.log('WebSocket connected');
+        sendWebSocketMessage('hello', { protocol_version: PROTOCOL_VERSION });
         sendWebSocketMessage('get_state', {});
     };
 
@@ -188,16 +332,178 @@ function handleWebSocketMessage(message) {
     console.log('Received:', message);
 
     switch (message.type) {
-        case 'game_state':
-            updateGameState(message.data);
+        case 'welcome':
+            console.log('Protocol version:', message.data.protocol_version);
+            break;
+        case 'state_snapshot':
+            gameState.stateSeq = message.data.seq;
+            updateGameState(message.data.state);
+            break;
+        case 'state_patch':
+            handleStatePatch(message.data);
+            break;
+        case 'chat_history':
+            document.getElementById('chatMessages').innerHTML = '';
+            message.data.messages.forEach(addChatMessage);
+            break;
+        case 'chat_message':
+            addChatMessage(message.data);
+            if (document.getElementById('chatPanel').classList.contains('collapsed')) {
+                setUnread(gameState.unread + 1);
+            }
+            break;
+        case 'reaction':
+            showReaction(message.data);
             break;
         case 'player_joined':
             console.log('Player joined:', message.data);
             break;
-        case 'error':
+        case 'player_left':
+            handlePlayerLeft(message.data);
+            break;
+        case 'host_changed':
+            if (message.data.host_id === gameState.playerId) {
+                showError('You are now the host');
+            } else {
+                showError(`${message.data.host_name} is now the host`);
+            }
+            break;
+        case 'expired':
+            showError('This game was closed after a period of inactivity.');
+            resetGame();
+            break;
+        case 'server_shutting_down':
+            showError('The server is restarting. Your game has ended.');
+            break;
+        case 'server_notice':
             showError(message.data.message);
             break;
+        case 'invite':
+            copyInviteLink(message.data);
+            break;
+        case 'game_closed':
+            showError('This game was closed by the server operator.');
+            resetGame();
+            break;
+        case 'error':
+            console.warn('Server error:', message.data.code);
+            showError(errorText(message.data, 'Something went wrong'));
+            break;
+    }
+}
+
+// Append a chat message to the panel
+function addChatMessage(data) {
+    const list = document.getElementById('chatMessages');
+    const li = document.createElement('li');
+    if (data.player_id === gameState.playerId) {
+        li.classList.add('mine');
+    }
+
+    const name = document.createElement('span');
+    name.className = 'chat-name';
+    name.textContent = data.player_name;
+    const time = document.createElement('span');
+    time.className = 'chat-time';
+    time.textContent = new Date(data.sent_at).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+
+    li.append(name, ' ', data.text, ' ', time);
+    list.appendChild(li);
+    list.scrollTop = list.scrollHeight;
+}
+
+// Show the unread chat count on the collapsed panel
+function setUnread(count) {
+    gameState.unread = count;
+    document.getElementById('chatUnread').textContent = count > 0 ? count : '';
+}
+
+// Float a reaction up the screen for a moment
+function showReaction(data) {
+    const el = document.createElement('div');
+    el.className = 'floating-reaction';
+    el.style.left = `${10 + Math.random() * 80}%`;
+
+    const emoji = document.createElement('span');
+    emoji.textContent = data.emoji;
+    const name = document.createElement('small');
+    name.textContent = data.player_name;
+    el.append(emoji, name);
+
+    document.getElementById('reactionLayer').appendChild(el);
+    setTimeout(() => el.remove(), 2500);
+}
+
+// Apply a state patch, asking for a fresh snapshot if one was missed
+function handleStatePatch(data) {
+    if (gameState.stateSeq === null) {
+        return; // A resync is already on its way
+    }
+    if (!gameState.currentGame || data.seq !== gameState.stateSeq + 1) {
+        console.warn(`State patch ${data.seq} does not follow ${gameState.stateSeq}, resyncing`);
+        sendWebSocketMessage('resync', { last_seq: gameState.stateSeq });
+        gameState.stateSeq = null;
+        return;
+    }
+
+    try {
+        gameState.currentGame = applyPatch(gameState.currentGame, data.ops);
+    } catch (e) {
+        console.warn('Could not apply state patch, resyncing:', e);
+        sendWebSocketMessage('resync', { last_seq: gameState.stateSeq });
+        gameState.stateSeq = null;
+        return;
     }
+    gameState.stateSeq = data.seq;
+    updateGameState(gameState.currentGame);
+}
+
+// Apply JSON Patch add, remove and replace operations to a document
+function applyPatch(doc, ops) {
+    for (const op of ops) {
+        if (op.path === '') {
+            doc = op.value;
+            continue;
+        }
+
+        const tokens = op.path.slice(1).split('/')
+            .map(token => token.replace(/~1/g, '/').replace(/~0/g, '~'));
+        const key = tokens.pop();
+        let parent = doc;
+        for (const token of tokens) {
+            parent = parent[Array.isArray(parent) ? Number(token) : token];
+            if (parent === undefined || parent === null) {
+                throw new Error(`bad path ${op.path}`);
+            }
+        }
+
+        if (Array.isArray(parent)) {
+            const index = key === '-' ? parent.length : Number(key);
+            if (op.op === 'add') parent.splice(index, 0, op.value);
+            else if (op.op === 'remove') parent.splice(index, 1);
+            else parent[index] = op.value;
+        } else if (op.op === 'remove') {
+            delete parent[key];
+        } else {
+            parent[key] = op.value;
+        }
+    }
+    return doc;
+}
+
+// Handle a player leaving or being kicked
+function handlePlayerLeft(data) {
+    if (data.player_id === gameState.playerId) {
+        if (data.reason === 'kicked') {
+            showError('You were removed from the game by the host');
+            resetGame();
+        }
+        return;
+    }
+
+    const action = data.reason === 'kicked' ? 'was removed' : 'left';
+    const suffix = data.replaced_by_bot ? ' - a bot will play their hand' : '';
+    showError(`${data.player_name} ${action}${suffix}`);
 }
 
 // Show lobby
@@ -226,6 +532,10 @@ function updateLobby(data) {
     playerList.innerHTML = '';
 
     document.getElementById('playerCount').textContent = data.players.length;
+    document.getElementById('maxPlayers').textContent = data.max_players;
+    document.getElementById('privateBadge').style.display = data.private ? 'block' : 'none';
+
+    const isHost = gameState.playerId === data.host_id;
 
     data.players.forEach(player => {
         const li = document.createElement('li');
@@ -233,14 +543,35 @@ function updateLobby(data) {
             li.classList.add('host');
         }
         li.textContent = player.name;
+
+        if (isHost && player.id !== gameState.playerId) {
+            const hostBtn = document.createElement('button');
+            hostBtn.className = 'btn-icon make-host-btn';
+            hostBtn.title = 'Make host';
+            hostBtn.textContent = '👑';
+            hostBtn.addEventListener('click', () => {
+                sendWebSocketMessage('transfer_host', { player_id: player.id });
+            });
+            li.appendChild(hostBtn);
+
+            const kickBtn = document.createElement('button');
+            kickBtn.className = 'btn-icon kick-btn';
+            kickBtn.title = 'Remove player';
+            kickBtn.textContent = '✖';
+            kickBtn.addEventListener('click', () => {
+                sendWebSocketMessage('kick_player', { player_id: player.id });
+            });
+            li.appendChild(kickBtn);
+        }
+
         playerList.appendChild(li);
     });
 
     // Show start button if host
-    if (gameState.playerId === data.host_id) {
+    if (isHost) {
         document.getElementById('hostControls').style.display = 'block';
         const startBtn = document.getElementById('startGameBtn');
-        startBtn.disabled = data.players.length < 2;
+        startBtn.disabled = data.players.length < data.min_players;
     } else {
         document.getElementById('hostControls').style.display = 'none';
     }
@@ -319,7 +650,7 @@ function renderOtherPlayers(players) {
         }
 
         playerBox.innerHTML = `
-            <div class="player-name">${player.name}</div>
+            <div class="player-name">${player.is_bot ? '🤖 ' : ''}${player.name}${player.connections > 1 ? ` (${player.connections} devices)` : ''}</div>
             <div class="player-score">Score: ${player.score}</div>
             <div class="player-status">
                 Hand: ${player.hand_size} cards |
@@ -393,6 +724,12 @@ function showFinalScores(data) {
 
 // Leave game
 function leaveGame() {
+    sendWebSocketMessage('leave_game', {});
+    resetGame();
+}
+
+// Drop the connection and return to the home screen
+function resetGame() {
     if (gameState.ws) {
         gameState.ws.close();
     }
@@ -400,7 +737,14 @@ function leaveGame() {
         gameId: null,
         playerId: null,
         ws: null,
-        currentGame: null
+        currentGame: null,
+        stateSeq: null,
+        unread: 0
     };
+    document.getElementById('chatMessages').innerHTML = '';
+    document.getElementById('chatUnread').textContent = '';
+    document.getElementById('chatPanel').style.display = 'none';
     showScreen('homeScreen');
 }
+
+openInviteLink();
@@ -0,0 +1,10 @@
+// Package static embeds the web client so the server binary is self-contained.
+package static
+
+import "embed"
+
+// FS holds the HTML, CSS and JavaScript served at /, along with any .br or
+// .gz files precompressed next to them
+//
+//go:embed *.html* css js
+var FS embed.FS